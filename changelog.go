@@ -0,0 +1,78 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"yum-packages-diff/yumdiff"
+)
+
+// writeChangelog writes the changelog entries of the new build of every
+// upgraded package which are dated after the newest entry of the old build,
+// only the changelogs of the upgraded builds are kept from the other data
+func writeChangelog(ctx context.Context, out io.Writer, h header, jobs []*diffJob) error {
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, "# Yum-diff changelog, version:", h.Version)
+	fmt.Fprintln(w, "#", reportSource(h))
+	for _, job := range jobs {
+		if !job.newRepo.HasOther() || !job.oldRepo.HasOther() {
+			log.Println("No changelogs to compare for", job.repoPath)
+			continue
+		}
+		var upgraded []yumdiff.Change
+		newIDs, oldIDs := make(map[string]bool), make(map[string]bool)
+		for _, c := range job.result.Classify() {
+			if c.Class == yumdiff.ClassUpgraded {
+				upgraded = append(upgraded, c)
+				newIDs[c.New.Entry().Checksum] = true
+				oldIDs[c.Old.Entry().Checksum] = true
+			}
+		}
+		if len(upgraded) == 0 {
+			continue
+		}
+		if err := job.newRepo.LoadOther(ctx, newIDs); err != nil {
+			return err
+		}
+		if err := job.oldRepo.LoadOther(ctx, oldIDs); err != nil {
+			return err
+		}
+
+		for _, c := range upgraded {
+			newData, okNew := job.newRepo.OtherData(c.New)
+			oldData, okOld := job.oldRepo.OtherData(c.Old)
+			if !okNew || !okOld {
+				log.Println("Missing changelog for", c.New.Entry().NEVRA)
+				continue
+			}
+			entries := yumdiff.NewChangelogs(oldData, newData)
+			if len(entries) == 0 {
+				continue
+			}
+			o, n := c.Old.Entry(), c.New.Entry()
+			fmt.Fprintf(w, "%s.%s %s -> %s\n", n.Name, n.Arch, o.EVR(), n.EVR())
+			for _, e := range entries {
+				fmt.Fprintf(w, "* %s %s\n%s\n\n", e.Time().Format("Mon Jan 02 2006"), e.Author, strings.TrimSpace(e.Text))
+			}
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,140 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"yum-packages-diff/yumdiff"
+)
+
+// listModified is the list of the advisories changed between the repositories
+const listModified = "modified"
+
+// newErrataWriter returns the writer for the errata diff, only the text, json
+// and ndjson formats make sense for advisories
+func newErrataWriter(format string, out io.Writer, lists []string) (resultWriter, error) {
+	switch format {
+	case "text":
+		return &errataTextWriter{out: out}, nil
+	case "json":
+		return newJSONWriter(out, lists), nil
+	case "ndjson":
+		return &ndjsonWriter{enc: json.NewEncoder(out)}, nil
+	}
+	return nil, fmt.Errorf("The errata diff can not be written in the %s format", format)
+}
+
+// advisoryOutput gives the output entry of an advisory
+func advisoryOutput(a yumdiff.Advisory) outputEntry {
+	return outputEntry{Entry: a.Entry(), Advisory: &advisoryEntry{
+		ID: a.ID, Type: a.Type, Severity: a.Severity,
+		Title: a.Title, Updated: a.Updated.Date, Packages: a.PackageSet(),
+	}}
+}
+
+// writeErrata feeds the advisories which were added, removed or modified (and
+// the common ones when asked for) of every job through the writer
+func writeErrata(w resultWriter, h header, jobs []*diffJob, lists []string) error {
+	var s summary
+	if err := w.Begin(h); err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.newRepo == nil || job.newRepo.Updates == nil {
+			log.Println("No updateinfo in the new repository for", job.repoPath)
+		}
+		r, err := yumdiff.DiffAdvisories(job.oldRepo, job.newRepo)
+		if err != nil {
+			return err
+		}
+		for _, list := range lists {
+			var entries []outputEntry
+			switch list {
+			case listAdded:
+				for _, m := range r.Added {
+					entries = append(entries, advisoryOutput(m.(yumdiff.Advisory)))
+				}
+			case listRemoved:
+				for _, m := range r.Removed {
+					entries = append(entries, advisoryOutput(m.(yumdiff.Advisory)))
+				}
+			case listCommon:
+				for _, m := range r.Common {
+					entries = append(entries, advisoryOutput(m.(yumdiff.Advisory)))
+				}
+			case listModified:
+				for _, c := range r.Modified {
+					e, old := advisoryOutput(c.New), advisoryOutput(c.Old)
+					e.Advisory.PackagesAdded, e.Advisory.PackagesRemoved = c.PackagesAdded, c.PackagesRemoved
+					e.Old = &old
+					entries = append(entries, e)
+				}
+			}
+			for _, e := range entries {
+				if err := w.Write(list, e); err != nil {
+					return err
+				}
+				*s.count(list)++
+			}
+		}
+	}
+	return w.End(s)
+}
+
+// errataTextWriter writes a line for each advisory, marked + when added, -
+// when removed and ~ when modified, with the package changes of the modified
+// advisories below them
+type errataTextWriter struct {
+	out io.Writer
+}
+
+func (t *errataTextWriter) Begin(h header) error {
+	fmt.Fprintln(t.out, "# Yum-diff errata, version:", h.Version)
+	_, err := fmt.Fprintln(t.out, "#", reportSource(h))
+	return err
+}
+
+func (t *errataTextWriter) Write(list string, e outputEntry) error {
+	a := e.Advisory
+	mark := map[string]string{listAdded: "+", listRemoved: "-", listModified: "~"}[list]
+	if mark == "" {
+		mark = " "
+	}
+	severity := a.Severity
+	if severity == "" {
+		severity = "-"
+	}
+	updated := a.Updated
+	if e.Old != nil && e.Old.Advisory.Updated != a.Updated {
+		updated = e.Old.Advisory.Updated + " -> " + a.Updated
+	}
+	fmt.Fprintf(t.out, "%s %s %s %s updated %s: %s\n", mark, a.ID, a.Type, severity, updated, a.Title)
+	for _, p := range a.PackagesAdded {
+		fmt.Fprintln(t.out, "    +", p)
+	}
+	for _, p := range a.PackagesRemoved {
+		fmt.Fprintln(t.out, "    -", p)
+	}
+	return nil
+}
+
+func (t *errataTextWriter) End(s summary) error {
+	_, err := fmt.Fprintf(t.out, "# %d added, %d removed, %d modified\n", s.Added, s.Removed, s.Modified)
+	return err
+}
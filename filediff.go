@@ -0,0 +1,110 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"yum-packages-diff/yumdiff"
+)
+
+// writeFileDiff writes the paths added (+) and removed (-) by the new build of
+// every upgraded package, only the file lists of the upgraded builds are kept
+// from the filelists
+func writeFileDiff(ctx context.Context, out io.Writer, h header, jobs []*diffJob) error {
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, "# Yum-diff file changes, version:", h.Version)
+	fmt.Fprintln(w, "#", reportSource(h))
+	for _, job := range jobs {
+		if !job.newRepo.HasFileLists() || !job.oldRepo.HasFileLists() {
+			log.Println("No filelists to compare for", job.repoPath)
+			continue
+		}
+		var upgraded []yumdiff.Change
+		newIDs, oldIDs := make(map[string]bool), make(map[string]bool)
+		for _, c := range job.result.Classify() {
+			if c.Class == yumdiff.ClassUpgraded {
+				upgraded = append(upgraded, c)
+				newIDs[c.New.Entry().Checksum] = true
+				oldIDs[c.Old.Entry().Checksum] = true
+			}
+		}
+		if len(upgraded) == 0 {
+			continue
+		}
+		if err := job.newRepo.LoadFileLists(ctx, newIDs); err != nil {
+			return err
+		}
+		if err := job.oldRepo.LoadFileLists(ctx, oldIDs); err != nil {
+			return err
+		}
+
+		for _, c := range upgraded {
+			newFiles, okNew := job.newRepo.FileList(c.New)
+			oldFiles, okOld := job.oldRepo.FileList(c.Old)
+			if !okNew || !okOld {
+				log.Println("Missing filelist for", c.New.Entry().NEVRA)
+				continue
+			}
+			added, removed := yumdiff.FileDiff(oldFiles, newFiles)
+			if len(added) == 0 && len(removed) == 0 {
+				continue
+			}
+			o, n := c.Old.Entry(), c.New.Entry()
+			fmt.Fprintf(w, "%s.%s %s -> %s\n", n.Name, n.Arch, o.EVR(), n.EVR())
+			for _, f := range added {
+				fmt.Fprintln(w, "+", f)
+			}
+			for _, f := range removed {
+				fmt.Fprintln(w, "-", f)
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// writeWhatProvides writes the packages shipping the paths matching the
+// pattern, in both the new and old repositories
+func writeWhatProvides(ctx context.Context, out io.Writer, h header, pattern yumdiff.Pattern, jobs []*diffJob) error {
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, "# Yum-diff what provides, version:", h.Version)
+	fmt.Fprintln(w, "#", reportSource(h))
+	for _, job := range jobs {
+		for _, side := range []struct {
+			name string
+			repo *yumdiff.Repo
+		}{{"new", job.newRepo}, {"old", job.oldRepo}} {
+			if !side.repo.HasFileLists() {
+				continue
+			}
+			found, err := side.repo.WhatProvides(ctx, pattern)
+			if err != nil {
+				return err
+			}
+			for _, f := range found {
+				for _, file := range f.Files {
+					if pattern.Match(file.Path) {
+						fmt.Fprintf(w, "%s %s %s\n", side.name, f.NEVRA(), file.Path)
+					}
+				}
+			}
+		}
+	}
+	return w.Flush()
+}
@@ -1,8 +1,16 @@
 module yum-packages-diff
 
-go 1.16
+go 1.21
 
 require (
+	github.com/ProtonMail/go-crypto v1.1.6
 	github.com/dustin/go-humanize v1.0.0
+	github.com/klauspost/compress v1.17.11
 	github.com/ulikunitz/xz v0.5.10
 )
+
+require (
+	github.com/cloudflare/circl v1.3.7 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+)
@@ -0,0 +1,85 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"yum-packages-diff/yumdiff"
+)
+
+// groupsJob is the comps membership changes of one repository pair
+type groupsJob struct {
+	RepoPath string                `json:"repoPath"`
+	Changes  []yumdiff.CompsChange `json:"changes"`
+}
+
+// writeGroups writes the membership changes of the comps groups, environments
+// and categories, in either the text or json format
+func writeGroups(out io.Writer, format string, h header, jobs []*diffJob) error {
+	var groups []groupsJob
+	for _, job := range jobs {
+		var oldComps, newComps *yumdiff.Comps
+		if job.oldRepo != nil {
+			oldComps = job.oldRepo.Comps
+		}
+		if job.newRepo != nil {
+			newComps = job.newRepo.Comps
+		}
+		if oldComps == nil && newComps == nil {
+			log.Println("No comps groups to compare for", job.repoPath)
+		}
+		groups = append(groups, groupsJob{RepoPath: job.repoPath, Changes: yumdiff.DiffComps(oldComps, newComps)})
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			header
+			Groups []groupsJob `json:"groups"`
+		}{h, groups})
+	case "text":
+	default:
+		return fmt.Errorf("The groups report can not be written in the %s format", format)
+	}
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, "# Yum-diff groups, version:", h.Version)
+	fmt.Fprintln(w, "#", reportSource(h))
+	for _, g := range groups {
+		if len(jobs) > 1 {
+			fmt.Fprintln(w, "#", g.RepoPath)
+		}
+		for _, c := range g.Changes {
+			fmt.Fprintf(w, "%s %s (%s) %s\n", c.Kind, c.ID, c.Name, c.Status)
+			for _, m := range c.Added {
+				fmt.Fprintf(w, "  + %s %s\n", m.Type, m.Name)
+			}
+			for _, m := range c.Removed {
+				fmt.Fprintf(w, "  - %s %s\n", m.Type, m.Name)
+			}
+			for _, m := range c.Changed {
+				fmt.Fprintf(w, "  ~ %s %s -> %s\n", m.Name, m.OldType, m.NewType)
+			}
+		}
+	}
+	return w.Flush()
+}
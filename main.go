@@ -15,19 +15,103 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path"
-	"strconv"
+	"runtime"
 	"strings"
 
-	humanize "github.com/dustin/go-humanize"
+	"yum-packages-diff/yumdiff"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
 )
 
 var version = "test"
-var repoPath string
+
+// infoOut gets the progress lines, which are moved to stderr for the machine
+// readable formats
+var infoOut io.Writer = os.Stdout
+
+// diffJob is the comparison of one new and old repository pair, the repoPath is
+// the path used for the entries in the file list
+type diffJob struct {
+	repoPath string
+	result   *yumdiff.Result
+	// oldRepo and newRepo are kept for the filelists, either may be nil
+	oldRepo, newRepo *yumdiff.Repo
+	// changes are the classified entries, only set when -show is used
+	changes []yumdiff.Change
+	// advisories filters the new entries when set
+	advisories *yumdiff.AdvisoryFilter
+}
+
+// list returns the entries of the named list of the result, or of the change
+// class when the job is classified.  The entry shown is New, or Old for the
+// removed entries.
+func (j *diffJob) list(name string) []yumdiff.Change {
+	var list []yumdiff.Change
+	switch {
+	case j.changes != nil && name != listCommon:
+		for _, c := range j.changes {
+			if c.Class.String() == name {
+				list = append(list, c)
+			}
+		}
+	case name == listAdded:
+		for _, m := range j.result.Added {
+			list = append(list, yumdiff.Change{Class: yumdiff.ClassNew, New: m})
+		}
+	case name == listRemoved:
+		for _, m := range j.result.Removed {
+			list = append(list, yumdiff.Change{Class: yumdiff.ClassRemoved, Old: m})
+		}
+	case name == listCommon:
+		for _, m := range j.result.Common {
+			list = append(list, yumdiff.Change{New: m})
+		}
+	}
+
+	kept := list[:0]
+	for _, c := range list {
+		if j.keep(c) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// keep reports if the change passes the advisory filter, only the entries from
+// the new repository are filtered as the advisories are of the new repository
+func (j *diffJob) keep(c yumdiff.Change) bool {
+	if j.advisories == nil || c.New == nil {
+		return true
+	}
+	return j.advisories.MatchAny(j.newRepo.Advisories(c.New))
+}
+
+// listFlag is a flag which can be given more than once
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, " ") }
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// loadOptions are the settings shared by every repository which is loaded
+type loadOptions struct {
+	keyring openpgp.EntityList
+	fields  yumdiff.Fields
+	diff    yumdiff.DiffOptions
+	// groups limits the diff to the packages of the comps groups, when set
+	groups []string
+}
 
 // HelloGet is an HTTP Cloud Function.
 func main() {
@@ -36,185 +120,400 @@ func main() {
 		flag.PrintDefaults()
 	}
 
-	var newFile = flag.String("new", "NewPrimary.xml.gz", "The newer Package.xml file or repodata/ dir for comparison")
-	var oldFile = flag.String("old", "OldPrimary.xml.gz", "The older Package.xml file or repodata/ dir for comparison")
+	var newFile = flag.String("new", "NewPrimary.xml.gz", "The newer Package.xml file, repodata/ dir, repository URL, mirrorlist=URL or metalink=URL for comparison")
+	var oldFile = flag.String("old", "OldPrimary.xml.gz", "The older Package.xml file, repodata/ dir, repository URL, mirrorlist=URL or metalink=URL for comparison")
 	var inRepoPath = flag.String("repo", "/7/os/x86_64", "Repo path to use in file list")
 	var outputFile = flag.String("output", "-", "Output for comparison result")
 	var showNew = flag.Bool("showAdded", false, "Display packages only in the new list")
 	var showOld = flag.Bool("showRemoved", false, "Display packages only in the old list")
 	var showCommon = flag.Bool("showCommon", false, "Display packages in both the new and old lists")
+	var latestN = flag.Int("latestN", 0, "Keep only the N newest builds of every package name and arch in the new list (0 keeps all)")
+	var keyringFile = flag.String("keyring", "", "Armored public key file(s), comma separated, used to verify the repomd.xml.asc signature")
+	var latestNew = flag.Bool("latestNew", false, "Keep only the newest build of every package name and arch in the new list")
+	var repoFile = flag.String("repofile", "", "Yum .repo file, every enabled repo is used as a new source and -old is the local mirror root")
+	var repoFilePrefix = flag.String("repofilePrefix", "id", "Path prefix used for each repo of the -repofile, either the section \"id\" or the \"baseurl\" path")
+	var releasever = flag.String("releasever", "", "Value for $releasever in the -repofile")
+	var basearch = flag.String("basearch", defaultBasearch(), "Value for $basearch in the -repofile")
+	var format = flag.String("format", "text", "Output format, either \"text\", \"json\", \"ndjson\" (one JSON object per line), the download lists \"aria2\", \"wget\", \"curl\" or \"metalink\", or \"sumfile\" for sha256sum -c")
+	var report = flag.String("report", "", "Write a change report of the classified packages instead of the file list, either \"html\" or \"markdown\"")
+	var show = flag.String("show", "", "Classify the changes and display the comma separated classes: new, removed, upgraded, downgraded, rebuilt (same NEVRA, different checksum) or moved (same checksum, different href)")
+	var includes, excludes, arches listFlag
+	flag.Var(&includes, "include", "Keep only the packages with a name or href matching the glob, or re:regex, can be repeated")
+	flag.Var(&excludes, "exclude", "Drop the packages with a name or href matching the glob, or re:regex, can be repeated")
+	flag.Var(&arches, "arch", "Keep only the packages of the arch(es), comma separated, can be repeated")
+	var fileDiff = flag.Bool("filediff", false, "Write the files added and removed by every upgraded package instead of the file list, from the filelists of the repodata/ dirs")
+	var whatProvides = flag.String("whatprovides", "", "Write the packages in the new and old repodata/ dirs which ship a path matching the glob, or re:regex, instead of the file list")
+	var changelog = flag.Bool("changelog", false, "Write the changelog entries newer than the old build of every upgraded package instead of the file list, from the other data of the repodata/ dirs")
+	var advisoryType = flag.String("advisory-type", "", "Keep only the new packages covered by an advisory of the type(s), comma separated, such as \"security\"")
+	var minSeverity = flag.String("min-severity", "", "Keep only the new packages covered by an advisory of at least the severity: Low, Moderate, Important or Critical")
+	var errata = flag.Bool("errata", false, "Write the advisories of the updateinfo which were added, removed or modified instead of the file list")
+	var groups = flag.Bool("groups", false, "Write the membership changes of the comps groups, environments and categories instead of the file list")
+	var includeGroup = flag.String("include-group", "", "Keep only the packages of the comps group(s), comma separated, such as \"core,base\"")
+	var baseURL = flag.String("baseurl", "", "Base mirror URL(s), comma separated, the file paths are joined onto for the download list formats")
 
 	flag.Parse()
 
-	var newPackages = []Matchable{}
-	var oldPackages = []Matchable{}
+	switch *format {
+	case "text":
+	case "json", "ndjson", "sumfile":
+		infoOut = os.Stderr
+	case "aria2", "wget", "curl", "metalink":
+		if *baseURL == "" {
+			log.Fatal("The ", *format, " format needs a -baseurl to download from")
+		}
+		infoOut = os.Stderr
+	default:
+		log.Fatal("Unknown output format: ", *format)
+	}
 
-	if _, isdir := isDirectory(*newFile); *newFile != "" {
-		if isdir {
-			newRepomd := readRepomdFile(path.Join(*newFile, "repomd.xml"))
-			if newRepomd == nil {
-				fmt.Println("Error reading in repomd.xml file, check that the file is a valid repomd.xml or the path is correct")
-				return
-			}
-			for _, d := range newRepomd.Data {
-				if d.Type == "primary" {
-					_, f := path.Split(d.Location.Href)
-					pkgs := readFile(path.Join(*newFile, f))
-					fmt.Println("# Loaded", len(pkgs), "new packages")
-					newPackages = append(newPackages, pkgs...)
-				}
-				if d.Type == "prestodelta" {
-					_, f := path.Split(d.Location.Href)
-					pkgs := readDeltaFile(path.Join(*newFile, f))
-					fmt.Println("# Loaded", len(pkgs), "new deltas")
-					newPackages = append(newPackages, pkgs...)
-				}
-			}
-		} else {
-			newPackages = readFile(*newFile)
+	switch *report {
+	case "":
+	case "html", "markdown":
+		infoOut = os.Stderr
+	default:
+		log.Fatal("Unknown report format: ", *report)
+	}
+
+	var classes []string
+	if *show != "" {
+		if *showNew || *showOld {
+			log.Fatal("The -show classes can not be combined with -showAdded or -showRemoved")
+		}
+		for _, name := range strings.Split(*show, ",") {
+			_, err := yumdiff.ParseClass(name)
+			check(err)
+			classes = append(classes, name)
 		}
 	}
 
-	/*if *latestNew {
-		var packagesByName = make(map[string]Package)
-		for _, p := range newPackages {
-			if pn, ok := packagesByName[p.Name]; ok {
-				if pn.Time.Build < p.Time.Build {
-					packagesByName[p.Name] = p
-				}
-			} else {
-				packagesByName[p.Name] = p
-			}
+	ctx := context.Background()
+	opts := loadOptions{
+		diff: yumdiff.DiffOptions{LatestNew: *latestNew, LatestN: *latestN},
+	}
+	if *keyringFile != "" {
+		var err error
+		opts.keyring, err = yumdiff.LoadKeyring(ctx, strings.Split(*keyringFile, ","))
+		check(err)
+	}
+
+	if len(includes) > 0 || len(excludes) > 0 || len(arches) > 0 {
+		f := &yumdiff.Filter{}
+		for _, s := range includes {
+			p, err := yumdiff.ParsePattern(s)
+			check(err)
+			f.Include = append(f.Include, p)
+		}
+		for _, s := range excludes {
+			p, err := yumdiff.ParsePattern(s)
+			check(err)
+			f.Exclude = append(f.Exclude, p)
+		}
+		for _, s := range arches {
+			f.Arch = append(f.Arch, strings.Split(s, ",")...)
+		}
+		opts.diff.Filter = f
+	}
+
+	var advisories *yumdiff.AdvisoryFilter
+	if *advisoryType != "" || *minSeverity != "" {
+		var types []string
+		if *advisoryType != "" {
+			types = strings.Split(*advisoryType, ",")
 		}
+		var err error
+		advisories, err = yumdiff.NewAdvisoryFilter(types, *minSeverity)
+		check(err)
+	}
+	// The advisories annotate the machine readable formats and the reports
+	if advisories != nil || *format == "json" || *format == "ndjson" || *report != "" || *errata {
+		opts.fields |= yumdiff.FieldNEVRA | yumdiff.FieldAdvisories
+	}
+
+	if *includeGroup != "" {
+		opts.groups = strings.Split(*includeGroup, ",")
+	}
+	if *groups || *includeGroup != "" {
+		opts.fields |= yumdiff.FieldNEVRA | yumdiff.FieldGroups
+	}
+
+	var providesPattern yumdiff.Pattern
+	if *whatProvides != "" {
+		var err error
+		providesPattern, err = yumdiff.ParsePattern(*whatProvides)
+		check(err)
+	}
+	if *fileDiff || *whatProvides != "" {
+		opts.fields |= yumdiff.FieldNEVRA
+	}
+	if *changelog {
+		opts.fields |= yumdiff.FieldNEVRA
+	}
+
+	// Only decode the package fields the selected mode needs
+	if *latestNew || *latestN > 0 || *format != "text" || *report != "" || *show != "" || opts.diff.Filter != nil {
+		opts.fields |= yumdiff.FieldNEVRA
+	}
 
-		newPackages = []Package{}
-		for _, p := range packagesByName {
-			newPackages = append(newPackages, p)
-		}
-	}*/
-
-	if _, isdir := isDirectory(*oldFile); *oldFile != "" {
-		if isdir {
-			oldRepomd := readRepomdFile(path.Join(*oldFile, "repomd.xml"))
-			for _, d := range oldRepomd.Data {
-				if d.Type == "primary" {
-					_, f := path.Split(d.Location.Href)
-					pkgs := readFile(path.Join(*oldFile, f))
-					fmt.Println("# Loaded", len(pkgs), "old packages")
-					oldPackages = append(oldPackages, pkgs...)
-				}
-				if d.Type == "prestodelta" {
-					_, f := path.Split(d.Location.Href)
-					pkgs := readDeltaFile(path.Join(*oldFile, f))
-					fmt.Println("# Loaded", len(pkgs), "old deltas")
-					oldPackages = append(oldPackages, pkgs...)
-				}
+	var jobs []*diffJob
+	if *repoFile != "" {
+		// The -repo path is only used as a root for the repo prefixes when it is set
+		var root string
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "repo" {
+				root = *inRepoPath
 			}
-		} else {
-			oldPackages = readFile(*oldFile)
+		})
+		vars := map[string]string{"basearch": *basearch, "arch": *basearch}
+		if *releasever != "" {
+			vars["releasever"] = *releasever
+		}
+		repos, err := yumdiff.ReadRepoFile(*repoFile, vars)
+		check(err)
+		for _, rc := range repos {
+			if !rc.Enabled {
+				log.Println("Skipping disabled repo", rc.ID)
+				continue
+			}
+			jobs = append(jobs, repoFileJob(ctx, rc, *repoFilePrefix, root, *oldFile, opts))
 		}
+	} else {
+		var newRepo, oldRepo *yumdiff.Repo
+		if *newFile != "" {
+			src, err := yumdiff.ResolveSource(ctx, *newFile)
+			check(err)
+			newRepo = loadRepo(ctx, src, "new", opts)
+		}
+		if *oldFile != "" {
+			src, err := yumdiff.ResolveSource(ctx, *oldFile)
+			check(err)
+			oldRepo = loadRepo(ctx, src, "old", opts)
+		}
+		jobs = append(jobs, diffRepos(oldRepo, newRepo, *inRepoPath, opts))
 	}
-	repoPath = strings.TrimSuffix(strings.TrimPrefix(*inRepoPath, "/"), "/")
 
+	// The sumfile format creates its own files, as it may need one per checksum type
 	out := os.Stdout
-	if *outputFile != "-" {
+	if *outputFile != "-" && (*format != "sumfile" || *report != "") {
 		f, err := os.Create(*outputFile)
 		check(err)
 		defer f.Close()
 		out = f
 	}
 
-	// initialized with zeros
-	newMatched := make([]int8, len(newPackages))
-	oldMatched := make([]int8, len(oldPackages))
+	h := header{Version: version, Old: *oldFile}
+	if *repoFile != "" {
+		h.RepoFile = *repoFile
+	} else {
+		h.New = *newFile
+	}
 
-	log.Println("doing matchups")
-matchups:
-	for iNew, pNew := range newPackages {
-		for iOld, pOld := range oldPackages {
-			//if reflect.DeepEqual(pNew, pOld) {
-			if pNew.matches(pOld) {
-				newMatched[iNew] = 1
-				oldMatched[iOld] = 1
-				continue matchups
-			}
+	switch {
+	case *whatProvides != "":
+		check(writeWhatProvides(ctx, out, h, providesPattern, jobs))
+		return
+	case *fileDiff:
+		check(writeFileDiff(ctx, out, h, jobs))
+		return
+	case *changelog:
+		check(writeChangelog(ctx, out, h, jobs))
+		return
+	case *groups:
+		check(writeGroups(out, *format, h, jobs))
+		return
+	case *errata:
+		lists := []string{listAdded, listRemoved, listModified}
+		if *showCommon {
+			lists = append(lists, listCommon)
 		}
+		w, err := newErrataWriter(*format, out, lists)
+		check(err)
+		check(writeErrata(w, h, jobs, lists))
+		return
 	}
 
-	fmt.Fprintln(out, "# Yum-diff matchup, version:", version)
-	fmt.Fprintln(out, "# new:", *newFile, "old:", *oldFile)
+	for _, job := range jobs {
+		job.advisories = advisories
+	}
 
-	var totalSize uint64
+	if *report != "" {
+		check(writeReport(out, *report, h, buildReport(jobs, classes)))
+		return
+	}
+
+	// The lists are written in the order added, common and removed, followed by
+	// the -show classes
+	var lists []string
 	if *showNew {
-		for iNew, v := range newPackages {
-			if newMatched[iNew] == 0 {
-				totalSize += atoi(v.size())
-			}
-		}
+		lists = append(lists, listAdded)
 	}
 	if *showCommon {
-		for iNew, v := range newPackages {
-			if newMatched[iNew] == 1 {
-				totalSize += atoi(v.size())
-			}
-		}
+		lists = append(lists, listCommon)
 	}
 	if *showOld {
-		for iOld, v := range oldPackages {
-			if oldMatched[iOld] == 0 {
-				totalSize += atoi(v.size())
-			}
+		lists = append(lists, listRemoved)
+	}
+	if len(classes) > 0 {
+		lists = append(lists, classes...)
+		for _, job := range jobs {
+			job.changes = job.result.Classify()
 		}
 	}
 
-	fmt.Fprintln(out, "# filelist size:", humanize.Bytes(totalSize))
+	wopts := writerOptions{lists: lists, outputFile: *outputFile}
+	if *baseURL != "" {
+		wopts.baseURLs = strings.Split(*baseURL, ",")
+	}
+	w, err := newResultWriter(*format, out, wopts)
+	check(err)
+	check(writeResults(w, h, jobs, lists))
+}
 
-	if *showNew {
-		for iNew, pNew := range newPackages {
-			if newMatched[iNew] == 0 {
-				// This package was not seen in OLD
-				pNew.print(out, repoPath)
-			}
+// loadRepo loads a repository with the shared options
+func loadRepo(ctx context.Context, src *yumdiff.Source, side string, opts loadOptions) *yumdiff.Repo {
+	if src.Keyring == nil {
+		src.Keyring = opts.keyring
+	}
+	src.Fields = opts.fields
+	repo, err := yumdiff.LoadRepo(ctx, src)
+	check(err)
+
+	var pkgs, deltas int
+	for _, m := range repo.Packages {
+		switch m.(type) {
+		case yumdiff.Package:
+			pkgs++
+		case yumdiff.DeltaPackage:
+			deltas++
 		}
 	}
+	fmt.Fprintln(infoOut, "# Loaded", pkgs, side, "packages")
+	if deltas > 0 {
+		fmt.Fprintln(infoOut, "# Loaded", deltas, side, "deltas")
+	}
+	return repo
+}
 
-	if *showCommon {
-		for iNew, pNew := range newPackages {
-			if newMatched[iNew] == 1 {
-				// This package was seen in BOTH
-				pNew.print(out, repoPath)
+// diffRepos does the matchup of one repository pair
+func diffRepos(oldRepo, newRepo *yumdiff.Repo, repoPath string, opts loadOptions) *diffJob {
+	if len(opts.groups) > 0 {
+		var err error
+		oldRepo, newRepo, err = yumdiff.InGroups(oldRepo, newRepo, opts.groups)
+		check(err)
+		for _, repo := range []*yumdiff.Repo{oldRepo, newRepo} {
+			if repo != nil && repo.Comps == nil {
+				log.Println("No comps groups in", repo.Mirror, "so the groups are taken from the other side")
 			}
 		}
 	}
+	log.Println("doing matchups")
+	result, err := yumdiff.Diff(oldRepo, newRepo, opts.diff)
+	check(err)
+	return &diffJob{
+		repoPath: strings.TrimSuffix(strings.TrimPrefix(repoPath, "/"), "/"),
+		result:   result,
+		oldRepo:  oldRepo,
+		newRepo:  newRepo,
+	}
+}
 
-	if *showOld {
-		for iOld, pOld := range oldPackages {
-			if oldMatched[iOld] == 0 {
-				// This package was not seen in NEW
-				pOld.print(out, repoPath)
-			}
+// repoFileJob loads the packages for one section of a .repo file, the old side
+// is looked up under the prefix of the repo in the oldRoot when one is given
+func repoFileJob(ctx context.Context, rc yumdiff.RepoConfig, prefixFrom, root, oldRoot string, opts loadOptions) *diffJob {
+	prefix := rc.PathPrefix(prefixFrom)
+
+	src, err := rc.Source(ctx)
+	check(err)
+	if (rc.RepoGPGCheck || rc.GPGCheck) && len(rc.GPGKey) > 0 {
+		src.Keyring, err = yumdiff.LoadKeyring(ctx, rc.GPGKey)
+		check(err)
+		// Only repo_gpgcheck means the repomd.xml is required to be signed
+		src.SignatureOptional = !rc.RepoGPGCheck
+	}
+	newRepo := loadRepo(ctx, src, "new", opts)
+
+	var oldRepo *yumdiff.Repo
+	if oldRoot != "" {
+		old := yumdiff.JoinPath(oldRoot, prefix)
+		if !yumdiff.IsURL(old) {
+			old = path.Join(old, "repodata")
+		}
+		if _, isdir := yumdiff.IsDirectory(old); isdir || yumdiff.IsURL(old) {
+			oldRepo = loadRepo(ctx, &yumdiff.Source{Mirrors: []string{old}}, "old", opts)
+		} else {
+			log.Println("No old repodata/ dir for repo", rc.ID, "at", old)
 		}
 	}
+	return diffRepos(oldRepo, newRepo, path.Join(root, prefix), opts)
 }
 
-func atoi(str string) uint64 {
-	i, _ := strconv.Atoi(str)
-	return uint64(i)
+// defaultBasearch maps the architecture of the running binary onto the yum
+// $basearch naming
+func defaultBasearch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386":
+		return "i386"
+	case "arm":
+		return "armhfp"
+	}
+	return runtime.GOARCH
 }
 
-func check(e error) {
-	if e != nil {
-		//panic(e)
-		log.Fatal(e)
+// sumSize adds up the file sizes of the entries shown
+func sumSize(list []yumdiff.Change) (total uint64) {
+	for _, c := range list {
+		total += shownEntry(c).FileSize()
 	}
+	return
 }
 
-// isDirectory determines if a file represented
-// by `path` is a directory or not
-func isDirectory(path string) (exist bool, isdir bool) {
-	fileInfo, err := os.Stat(path)
-	if err != nil {
-		return false, false
+// shownEntry is the entry of the change which is written out, the new one
+// unless it was removed
+func shownEntry(c yumdiff.Change) yumdiff.Matchable {
+	if c.New != nil {
+		return c.New
+	}
+	return c.Old
+}
+
+// The exit codes, so a wrapper script can tell a network problem apart from
+// corrupt metadata
+const (
+	exitError       = 1
+	exitNetwork     = 3
+	exitCorrupt     = 4
+	exitEmptyRepo   = 5
+	exitMissing     = 6
+	exitSignature   = 7
+	exitBadChecksum = 8
+)
+
+// exitCode maps an error onto the exit code, when the error holds more than
+// one cause (such as from several mirrors) the most severe one wins
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, yumdiff.ErrSignature):
+		return exitSignature
+	case errors.Is(err, yumdiff.ErrChecksumMismatch):
+		return exitBadChecksum
+	case errors.Is(err, yumdiff.ErrPackageCountMismatch), errors.Is(err, yumdiff.ErrMalformed):
+		return exitCorrupt
+	case errors.Is(err, yumdiff.ErrRepomdMissing), errors.Is(err, fs.ErrNotExist):
+		return exitMissing
+	case errors.Is(err, yumdiff.ErrEmptyRepo):
+		return exitEmptyRepo
+	case errors.Is(err, yumdiff.ErrNetwork):
+		return exitNetwork
+	}
+	return exitError
+}
+
+func check(e error) {
+	if e != nil {
+		log.Println(e)
+		os.Exit(exitCode(e))
 	}
-	return true, fileInfo.IsDir()
 }
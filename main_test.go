@@ -0,0 +1,112 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"yum-packages-diff/yumdiff"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("other"), exitError},
+		{yumdiff.ErrNetwork, exitNetwork},
+		{yumdiff.ErrMalformed, exitCorrupt},
+		{yumdiff.ErrPackageCountMismatch, exitCorrupt},
+		{yumdiff.ErrEmptyRepo, exitEmptyRepo},
+		{yumdiff.ErrRepomdMissing, exitMissing},
+		{fs.ErrNotExist, exitMissing},
+		{yumdiff.ErrSignature, exitSignature},
+		{yumdiff.ErrChecksumMismatch, exitBadChecksum},
+		{yumdiff.ErrUnsupportedCompression, exitError},
+
+		// Wrapped with the file name, as returned from yumdiff
+		{fmt.Errorf("%w for primary.xml.gz", yumdiff.ErrChecksumMismatch), exitBadChecksum},
+		{fmt.Errorf("%w, error in decoding Repomd repomd.xml: %w", yumdiff.ErrMalformed, errors.New("EOF")), exitCorrupt},
+
+		// Several causes, the most severe one wins
+		{errors.Join(yumdiff.ErrNetwork, yumdiff.ErrChecksumMismatch), exitBadChecksum},
+		{errors.Join(yumdiff.ErrNetwork, yumdiff.ErrRepomdMissing), exitMissing},
+		{errors.Join(yumdiff.ErrMalformed, yumdiff.ErrSignature), exitSignature},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSumWriter(t *testing.T) {
+	entries := []outputEntry{
+		{Entry: yumdiff.Entry{ChecksumType: "sha256", Checksum: "aa", Href: "a.rpm"}, Path: "7/a.rpm"},
+		{Entry: yumdiff.Entry{ChecksumType: "sha", Checksum: "bb", Href: "b.rpm"}, Path: "7/b.rpm"},
+	}
+	write := func(outputFile string, entries []outputEntry) (string, error) {
+		var buf bytes.Buffer
+		w := &sumWriter{out: &buf, outputFile: outputFile}
+		if err := w.Begin(header{}); err != nil {
+			return "", err
+		}
+		for _, e := range entries {
+			if err := w.Write("added", e); err != nil {
+				return "", err
+			}
+		}
+		err := w.End(summary{})
+		return buf.String(), err
+	}
+
+	// One checksum type goes to stdout
+	if got, err := write("-", entries[:1]); err != nil || got != "aa  7/a.rpm\n" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	// Mixed types on stdout need an -output file
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if got, err := write("-", entries); err == nil || got != "" {
+		t.Errorf("got %q, %v, want an error", got, err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("wrote %d files into the current dir", len(files))
+	}
+
+	outputFile := filepath.Join(dir, "sums")
+	if _, err := write(outputFile, entries); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{"sums.sha256": "aa  7/a.rpm\n", "sums.sha1": "bb  7/b.rpm\n"} {
+		if got, err := os.ReadFile(filepath.Join(dir, file)); err != nil || string(got) != want {
+			t.Errorf("%s: got %q, %v, want %q", file, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"yum-packages-diff/yumdiff"
+)
+
+// hashName maps the repodata checksum type onto the IANA hash name used by
+// aria2 and metalink, such as sha-256
+func hashName(checksumType string) string {
+	switch checksumType {
+	case "sha", "sha1":
+		return "sha-1"
+	case "sha224", "sha256", "sha384", "sha512":
+		return "sha-" + strings.TrimPrefix(checksumType, "sha")
+	}
+	return checksumType
+}
+
+// entryURLs builds the download URL of the entry on every base mirror
+func entryURLs(baseURLs []string, e outputEntry) []string {
+	urls := make([]string, len(baseURLs))
+	for i, base := range baseURLs {
+		urls[i] = yumdiff.JoinPath(base, e.Path)
+	}
+	return urls
+}
+
+// aria2Writer writes an aria2c input file, every mirror is listed as a URI of
+// the entry so aria2c can split the download between them
+type aria2Writer struct {
+	out      io.Writer
+	baseURLs []string
+}
+
+func (a *aria2Writer) Begin(h header) error { return nil }
+
+func (a *aria2Writer) Write(list string, e outputEntry) error {
+	_, err := fmt.Fprintf(a.out, "%s\n  checksum=%s=%s\n  out=%s\n",
+		strings.Join(entryURLs(a.baseURLs, e), "\t"), hashName(e.ChecksumType), e.Checksum, e.Path)
+	return err
+}
+
+func (a *aria2Writer) End(s summary) error { return nil }
+
+// wgetWriter writes the plain list of URLs on the first mirror, for wget -i
+type wgetWriter struct {
+	out     io.Writer
+	baseURL string
+}
+
+func (w *wgetWriter) Begin(h header) error { return nil }
+
+func (w *wgetWriter) Write(list string, e outputEntry) error {
+	_, err := fmt.Fprintln(w.out, yumdiff.JoinPath(w.baseURL, e.Path))
+	return err
+}
+
+func (w *wgetWriter) End(s summary) error { return nil }
+
+// curlWriter writes a curl config file of url and output pairs, for curl -K
+type curlWriter struct {
+	out     io.Writer
+	baseURL string
+}
+
+func (c *curlWriter) Begin(h header) error {
+	_, err := fmt.Fprintln(c.out, "create-dirs")
+	return err
+}
+
+func (c *curlWriter) Write(list string, e outputEntry) error {
+	_, err := fmt.Fprintf(c.out, "url = %s\noutput = %s\n",
+		curlQuote(yumdiff.JoinPath(c.baseURL, e.Path)), curlQuote(e.Path))
+	return err
+}
+
+func (c *curlWriter) End(s summary) error { return nil }
+
+// curlQuote quotes a value of the curl config file
+func curlQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// sumWriter writes the lines of sha256sum and friends, for checking the files
+// with sha256sum -c.  When the entries use more than one checksum type each
+// type is written to its own file, named by adding the type to the -output
+// name, which is then needed as stdout only holds one list.
+type sumWriter struct {
+	out        io.Writer
+	outputFile string
+	types      []string
+	lines      map[string][]string
+}
+
+func (w *sumWriter) Begin(h header) error {
+	w.lines = make(map[string][]string)
+	return nil
+}
+
+func (w *sumWriter) Write(list string, e outputEntry) error {
+	t := e.ChecksumType
+	if t == "sha" {
+		t = "sha1"
+	}
+	if _, ok := w.lines[t]; !ok {
+		w.types = append(w.types, t)
+	}
+	w.lines[t] = append(w.lines[t], e.Checksum+"  "+e.Path)
+	return nil
+}
+
+func (w *sumWriter) End(s summary) error {
+	if len(w.types) <= 1 {
+		var lines []string
+		for _, t := range w.types {
+			lines = w.lines[t]
+		}
+		if w.outputFile == "-" {
+			return writeLines(w.out, lines)
+		}
+		return writeLinesFile(w.outputFile, lines)
+	}
+	if w.outputFile == "-" {
+		return fmt.Errorf("The entries use the checksum types %s, an -output file is needed to write one list per type",
+			strings.Join(w.types, ", "))
+	}
+	for _, t := range w.types {
+		fileName := w.outputFile + "." + t
+		fmt.Fprintln(infoOut, "# Writing", len(w.lines[t]), t, "checksums to", fileName)
+		if err := writeLinesFile(fileName, w.lines[t]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLinesFile creates the file and writes the lines into it
+func writeLinesFile(fileName string, lines []string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err = writeLines(f, lines); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeLines(out io.Writer, lines []string) error {
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// meta4File is a file entry of a Metalink 4 (RFC 5854) document
+type meta4File struct {
+	XMLName xml.Name `xml:"file"`
+	Name    string   `xml:"name,attr"`
+	Size    uint64   `xml:"size"`
+	Hash    struct {
+		Text string `xml:",chardata"`
+		Type string `xml:"type,attr"`
+	} `xml:"hash"`
+	URLs []meta4URL `xml:"url"`
+}
+
+type meta4URL struct {
+	Text     string `xml:",chardata"`
+	Priority int    `xml:"priority,attr"`
+}
+
+// metalinkWriter writes a Metalink 4 .meta4 document with every mirror as a
+// url of the file, in the order of preference given
+type metalinkWriter struct {
+	out      io.Writer
+	enc      *xml.Encoder
+	baseURLs []string
+}
+
+var meta4Start = xml.StartElement{Name: xml.Name{Local: "metalink"},
+	Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "urn:ietf:params:xml:ns:metalink"}}}
+
+func (m *metalinkWriter) Begin(h header) error {
+	if _, err := io.WriteString(m.out, xml.Header); err != nil {
+		return err
+	}
+	m.enc = xml.NewEncoder(m.out)
+	m.enc.Indent("", "  ")
+	if err := m.enc.EncodeToken(meta4Start); err != nil {
+		return err
+	}
+	return m.enc.EncodeElement("yum-package-diff/"+h.Version, xml.StartElement{Name: xml.Name{Local: "generator"}})
+}
+
+func (m *metalinkWriter) Write(list string, e outputEntry) error {
+	f := meta4File{Name: e.Path, Size: e.Size}
+	f.Hash.Type, f.Hash.Text = hashName(e.ChecksumType), e.Checksum
+	for i, u := range entryURLs(m.baseURLs, e) {
+		f.URLs = append(f.URLs, meta4URL{Text: u, Priority: i + 1})
+	}
+	return m.enc.Encode(f)
+}
+
+func (m *metalinkWriter) End(s summary) error {
+	if err := m.enc.EncodeToken(meta4Start.End()); err != nil {
+		return err
+	}
+	if err := m.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(m.out)
+	return err
+}
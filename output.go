@@ -0,0 +1,317 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	"yum-packages-diff/yumdiff"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// The names of the lists an entry can be written from
+const (
+	listAdded   = "added"
+	listRemoved = "removed"
+	listCommon  = "common"
+)
+
+// header is the information about the run written at the top of the output
+type header struct {
+	Version   string `json:"version"`
+	New       string `json:"new,omitempty"`
+	RepoFile  string `json:"repofile,omitempty"`
+	Old       string `json:"old"`
+	TotalSize uint64 `json:"totalSize"`
+}
+
+// outputEntry is an entry of the output, the path includes the repo path.  The
+// classes which pair up an old and new entry hold the old one in Old, and the
+// new entries the advisories covering them.
+type outputEntry struct {
+	yumdiff.Entry
+	Path       string          `json:"path,omitempty"`
+	Advisories []advisoryEntry `json:"advisories,omitempty"`
+	Advisory   *advisoryEntry  `json:"advisory,omitempty"`
+	Old        *outputEntry    `json:"old,omitempty"`
+}
+
+// advisoryEntry is the summary of an advisory covering an entry, the rest of
+// the fields are only given for the entries of the errata diff
+type advisoryEntry struct {
+	ID              string   `json:"id"`
+	Type            string   `json:"type"`
+	Severity        string   `json:"severity,omitempty"`
+	Title           string   `json:"title,omitempty"`
+	Updated         string   `json:"updated,omitempty"`
+	Packages        []string `json:"packages,omitempty"`
+	PackagesAdded   []string `json:"packagesAdded,omitempty"`
+	PackagesRemoved []string `json:"packagesRemoved,omitempty"`
+}
+
+// newOutputEntry gives the shown entry of the change from the list, only the
+// added and upgraded entries are annotated with their advisories
+func newOutputEntry(job *diffJob, list string, c yumdiff.Change) outputEntry {
+	e := shownEntry(c).Entry()
+	ret := outputEntry{Entry: e, Path: path.Join(job.repoPath, e.Href)}
+	if c.New == nil {
+		return ret
+	}
+	// The common entries are also listed with only a new side
+	if list != listCommon && (c.Class == yumdiff.ClassNew || c.Class == yumdiff.ClassUpgraded) {
+		for _, u := range job.newRepo.Advisories(c.New) {
+			ret.Advisories = append(ret.Advisories, advisoryEntry{ID: u.ID, Type: u.Type, Severity: u.Severity})
+		}
+	}
+	if c.Old != nil {
+		old := newOutputEntry(job, list, yumdiff.Change{Class: yumdiff.ClassRemoved, Old: c.Old})
+		ret.Old = &old
+	}
+	return ret
+}
+
+// summary is the count of entries written from each list, the counts of the
+// -show classes are only given when used.  The new and removed classes are
+// counted as added and removed, as they can not be used with -showAdded or
+// -showRemoved and new is the name of the new repository in the header.
+type summary struct {
+	Added      int    `json:"added"`
+	Removed    int    `json:"removed"`
+	Common     int    `json:"common"`
+	Upgraded   int    `json:"upgraded,omitempty"`
+	Downgraded int    `json:"downgraded,omitempty"`
+	Rebuilt    int    `json:"rebuilt,omitempty"`
+	Moved      int    `json:"moved,omitempty"`
+	Modified   int    `json:"modified,omitempty"`
+	TotalSize  uint64 `json:"totalSize"`
+}
+
+// count returns the counter of the list
+func (s *summary) count(list string) *int {
+	switch list {
+	case listAdded:
+		return &s.Added
+	case listRemoved:
+		return &s.Removed
+	case listCommon:
+		return &s.Common
+	case "new":
+		return &s.Added
+	case "upgraded":
+		return &s.Upgraded
+	case "downgraded":
+		return &s.Downgraded
+	case "rebuilt":
+		return &s.Rebuilt
+	case "moved":
+		return &s.Moved
+	case listModified:
+		return &s.Modified
+	}
+	return new(int)
+}
+
+// resultWriter is an output format, Begin is called once, then Write for every
+// entry shown and End once all the entries are written.
+type resultWriter interface {
+	Begin(h header) error
+	Write(list string, e outputEntry) error
+	End(s summary) error
+}
+
+// writerOptions are the settings of the output formats
+type writerOptions struct {
+	lists      []string
+	baseURLs   []string
+	outputFile string
+}
+
+// newResultWriter returns the writer for the output format
+func newResultWriter(format string, out io.Writer, opts writerOptions) (resultWriter, error) {
+	switch format {
+	case "text":
+		return &textWriter{out: out}, nil
+	case "json":
+		return newJSONWriter(out, opts.lists), nil
+	case "ndjson":
+		return &ndjsonWriter{enc: json.NewEncoder(out)}, nil
+	case "aria2":
+		return &aria2Writer{out: out, baseURLs: opts.baseURLs}, nil
+	case "wget":
+		return &wgetWriter{out: out, baseURL: opts.baseURLs[0]}, nil
+	case "curl":
+		return &curlWriter{out: out, baseURL: opts.baseURLs[0]}, nil
+	case "metalink":
+		return &metalinkWriter{out: out, baseURLs: opts.baseURLs}, nil
+	case "sumfile":
+		return &sumWriter{out: out, outputFile: opts.outputFile}, nil
+	}
+	return nil, fmt.Errorf("Unknown output format: %s", format)
+}
+
+// writeResults feeds the shown lists of every job through the writer
+func writeResults(w resultWriter, h header, jobs []*diffJob, lists []string) error {
+	var s summary
+	for _, job := range jobs {
+		for _, list := range lists {
+			h.TotalSize += sumSize(job.list(list))
+		}
+	}
+	s.TotalSize = h.TotalSize
+
+	if err := w.Begin(h); err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		for _, list := range lists {
+			for _, c := range job.list(list) {
+				if err := w.Write(list, newOutputEntry(job, list, c)); err != nil {
+					return err
+				}
+				*s.count(list)++
+			}
+		}
+	}
+	return w.End(s)
+}
+
+// textWriter writes the {type}checksum size path file list
+type textWriter struct {
+	out io.Writer
+}
+
+func (t *textWriter) Begin(h header) error {
+	fmt.Fprintln(t.out, "# Yum-diff matchup, version:", h.Version)
+	if h.RepoFile != "" {
+		fmt.Fprintln(t.out, "# repofile:", h.RepoFile, "old:", h.Old)
+	} else {
+		fmt.Fprintln(t.out, "# new:", h.New, "old:", h.Old)
+	}
+	_, err := fmt.Fprintln(t.out, "# filelist size:", humanize.Bytes(h.TotalSize))
+	return err
+}
+
+func (t *textWriter) Write(list string, e outputEntry) error {
+	_, err := fmt.Fprintf(t.out, "{%s}%s %d %s\n", e.ChecksumType, e.Checksum, e.Size, e.Path)
+	return err
+}
+
+func (t *textWriter) End(s summary) error { return nil }
+
+// jsonWriter collects the entries and writes them out as one JSON document
+type jsonWriter struct {
+	out io.Writer
+	doc jsonDocument
+}
+
+// jsonDocument is the whole diff result, only the lists which were selected
+// for display are included.  The new class is held in added, as with summary.
+type jsonDocument struct {
+	header
+	Added      *[]outputEntry `json:"added,omitempty"`
+	Removed    *[]outputEntry `json:"removed,omitempty"`
+	Common     *[]outputEntry `json:"common,omitempty"`
+	Upgraded   *[]outputEntry `json:"upgraded,omitempty"`
+	Downgraded *[]outputEntry `json:"downgraded,omitempty"`
+	Rebuilt    *[]outputEntry `json:"rebuilt,omitempty"`
+	Moved      *[]outputEntry `json:"moved,omitempty"`
+	Modified   *[]outputEntry `json:"modified,omitempty"`
+}
+
+func newJSONWriter(out io.Writer, lists []string) *jsonWriter {
+	j := &jsonWriter{out: out}
+	for _, list := range lists {
+		if dst := j.list(list); dst != nil {
+			*dst = &[]outputEntry{}
+		}
+	}
+	return j
+}
+
+func (j *jsonWriter) list(list string) **[]outputEntry {
+	switch list {
+	case listAdded:
+		return &j.doc.Added
+	case listRemoved:
+		return &j.doc.Removed
+	case listCommon:
+		return &j.doc.Common
+	case "new":
+		return &j.doc.Added
+	case "upgraded":
+		return &j.doc.Upgraded
+	case "downgraded":
+		return &j.doc.Downgraded
+	case "rebuilt":
+		return &j.doc.Rebuilt
+	case "moved":
+		return &j.doc.Moved
+	case listModified:
+		return &j.doc.Modified
+	}
+	return nil
+}
+
+func (j *jsonWriter) Begin(h header) error {
+	j.doc.header = h
+	return nil
+}
+
+func (j *jsonWriter) Write(list string, e outputEntry) error {
+	dst := *j.list(list)
+	*dst = append(*dst, e)
+	return nil
+}
+
+func (j *jsonWriter) End(s summary) error {
+	enc := json.NewEncoder(j.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(j.doc)
+}
+
+// ndjsonWriter writes one JSON object per line for every entry as it comes,
+// followed by a summary object
+type ndjsonWriter struct {
+	enc *json.Encoder
+	h   header
+}
+
+func (n *ndjsonWriter) Begin(h header) error {
+	n.h = h
+	return nil
+}
+
+func (n *ndjsonWriter) Write(list string, e outputEntry) error {
+	return n.enc.Encode(struct {
+		Type string `json:"type"`
+		List string `json:"list"`
+		outputEntry
+	}{"entry", list, e})
+}
+
+func (n *ndjsonWriter) End(s summary) error {
+	return n.enc.Encode(struct {
+		Type     string `json:"type"`
+		Version  string `json:"version"`
+		New      string `json:"new,omitempty"`
+		RepoFile string `json:"repofile,omitempty"`
+		Old      string `json:"old"`
+		summary
+	}{"summary", n.h.Version, n.h.New, n.h.RepoFile, n.h.Old, s})
+}
@@ -0,0 +1,256 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"yum-packages-diff/yumdiff"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// reportTable is a titled table of the change report, with a line of totals
+// written below it
+type reportTable struct {
+	title   string
+	columns []string
+	rows    [][]string
+	total   string
+}
+
+// reportTotals counts the entries and bytes of a group over every job
+type reportTotals struct {
+	count            int
+	oldSize, newSize uint64
+}
+
+func (t *reportTotals) add(oldSize, newSize uint64) {
+	t.count++
+	t.oldSize += oldSize
+	t.newSize += newSize
+}
+
+// reportTitles are the table titles of the change classes
+var reportTitles = map[yumdiff.Class]string{
+	yumdiff.ClassNew:        "Added",
+	yumdiff.ClassRemoved:    "Removed",
+	yumdiff.ClassUpgraded:   "Upgraded",
+	yumdiff.ClassDowngraded: "Downgraded",
+	yumdiff.ClassRebuilt:    "Rebuilt",
+	yumdiff.ClassMoved:      "Moved",
+}
+
+// buildReport groups the classified changes of every job into a table per
+// class, after a summary table of the totals.  The classes are limited to the
+// names given, when any are.
+func buildReport(jobs []*diffJob, names []string) []reportTable {
+	classes := yumdiff.Classes
+	if len(names) > 0 {
+		classes = nil
+		for _, name := range names {
+			c, _ := yumdiff.ParseClass(name)
+			classes = append(classes, c)
+		}
+	}
+
+	totals := make(map[yumdiff.Class]*reportTotals)
+	for _, class := range classes {
+		totals[class] = &reportTotals{}
+	}
+	var tables []reportTable
+	for _, job := range jobs {
+		changes := job.result.Classify()
+		suffix := ""
+		if len(jobs) > 1 {
+			suffix = " - " + job.repoPath
+		}
+		// The new and upgraded entries get a column of their advisories when
+		// the new repository has an updateinfo
+		withAdvisories := job.newRepo != nil && job.newRepo.Updates != nil
+
+		for _, class := range classes {
+			t := reportTable{title: reportTitles[class] + suffix}
+			switch class {
+			case yumdiff.ClassNew, yumdiff.ClassRemoved:
+				t.columns = []string{"Name", "Arch", "EVR", "Size"}
+			case yumdiff.ClassUpgraded, yumdiff.ClassDowngraded:
+				t.columns = []string{"Name", "Arch", "Old EVR", "New EVR", "Size delta"}
+			case yumdiff.ClassRebuilt:
+				t.columns = []string{"Name", "Arch", "EVR", "Size delta"}
+			case yumdiff.ClassMoved:
+				t.columns = []string{"Name", "Arch", "Old href", "New href"}
+			}
+			annotate := withAdvisories && (class == yumdiff.ClassNew || class == yumdiff.ClassUpgraded)
+			if annotate {
+				t.columns = append(t.columns, "Advisories")
+			}
+
+			var sum reportTotals
+			for _, c := range changes {
+				if c.Class != class || !job.keep(c) {
+					continue
+				}
+				var o, n yumdiff.Entry
+				if c.Old != nil {
+					o = c.Old.Entry()
+				}
+				if c.New != nil {
+					n = c.New.Entry()
+				}
+				switch class {
+				case yumdiff.ClassNew:
+					t.rows = append(t.rows, []string{entryName(n), n.Arch, n.EVR().String(), humanize.Bytes(n.Size)})
+				case yumdiff.ClassRemoved:
+					t.rows = append(t.rows, []string{entryName(o), o.Arch, o.EVR().String(), humanize.Bytes(o.Size)})
+				case yumdiff.ClassUpgraded, yumdiff.ClassDowngraded:
+					t.rows = append(t.rows, []string{entryName(n), n.Arch, o.EVR().String(), n.EVR().String(), sizeDelta(o.Size, n.Size)})
+				case yumdiff.ClassRebuilt:
+					t.rows = append(t.rows, []string{entryName(n), n.Arch, n.EVR().String(), sizeDelta(o.Size, n.Size)})
+				case yumdiff.ClassMoved:
+					t.rows = append(t.rows, []string{entryName(n), n.Arch, o.Href, n.Href})
+				}
+				if annotate {
+					row := &t.rows[len(t.rows)-1]
+					*row = append(*row, advisoryList(job.newRepo.Advisories(c.New)))
+				}
+				sum.add(o.Size, n.Size)
+				totals[class].add(o.Size, n.Size)
+			}
+			t.total = fmt.Sprintf("%d %s, size delta %s", sum.count, class, sizeDelta(sum.oldSize, sum.newSize))
+			tables = append(tables, t)
+		}
+	}
+
+	summary := reportTable{title: "Summary", columns: []string{"Change", "Count", "Size delta"}}
+	var all reportTotals
+	for _, class := range classes {
+		t := totals[class]
+		summary.rows = append(summary.rows, []string{reportTitles[class], fmt.Sprint(t.count), sizeDelta(t.oldSize, t.newSize)})
+		all.oldSize += t.oldSize
+		all.newSize += t.newSize
+	}
+	summary.total = "Total size delta " + sizeDelta(all.oldSize, all.newSize)
+	return append([]reportTable{summary}, tables...)
+}
+
+// advisoryList gives the ids of the advisories with their type and severity
+func advisoryList(updates []yumdiff.Update) string {
+	var list []string
+	for _, u := range updates {
+		desc := u.Type
+		if u.Severity != "" {
+			desc += ", " + u.Severity
+		}
+		list = append(list, u.ID+" ("+desc+")")
+	}
+	return strings.Join(list, "; ")
+}
+
+// entryName marks the deltas, as they have the name of the package they build
+func entryName(e yumdiff.Entry) string {
+	if e.Kind == "delta" {
+		return e.Name + " (delta)"
+	}
+	return e.Name
+}
+
+// sizeDelta gives the signed and human readable change in size
+func sizeDelta(oldSize, newSize uint64) string {
+	if newSize >= oldSize {
+		return "+" + humanize.Bytes(newSize-oldSize)
+	}
+	return "-" + humanize.Bytes(oldSize-newSize)
+}
+
+// reportSource is the line describing what was compared
+func reportSource(h header) string {
+	if h.RepoFile != "" {
+		return fmt.Sprintf("repofile: %s old: %s", h.RepoFile, h.Old)
+	}
+	return fmt.Sprintf("new: %s old: %s", h.New, h.Old)
+}
+
+// writeReport writes the change report in either the html or markdown format
+func writeReport(out io.Writer, format string, h header, tables []reportTable) error {
+	switch format {
+	case "markdown":
+		return writeMarkdownReport(out, h, tables)
+	case "html":
+		return writeHTMLReport(out, h, tables)
+	}
+	return fmt.Errorf("Unknown report format: %s", format)
+}
+
+func writeMarkdownReport(out io.Writer, h header, tables []reportTable) error {
+	cell := strings.NewReplacer("|", `\|`, "\n", " ")
+	row := func(cols []string) string {
+		for i := range cols {
+			cols[i] = cell.Replace(cols[i])
+		}
+		return "| " + strings.Join(cols, " | ") + " |\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Yum-diff report\n\nVersion %s, %s\n", h.Version, cell.Replace(reportSource(h)))
+	for _, t := range tables {
+		fmt.Fprintf(&b, "\n## %s\n\n", t.title)
+		if len(t.rows) > 0 {
+			b.WriteString(row(append([]string{}, t.columns...)))
+			b.WriteString("|" + strings.Repeat(" --- |", len(t.columns)) + "\n")
+			for _, r := range t.rows {
+				b.WriteString(row(r))
+			}
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s\n", t.total)
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+func writeHTMLReport(out io.Writer, h header, tables []reportTable) error {
+	esc := html.EscapeString
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Yum-diff report</title>\n")
+	b.WriteString("<style>table{border-collapse:collapse}th,td{border:1px solid #ccc;padding:2px 8px;text-align:left}</style>\n")
+	b.WriteString("</head>\n<body>\n<h1>Yum-diff report</h1>\n")
+	fmt.Fprintf(&b, "<p>Version %s, %s</p>\n", esc(h.Version), esc(reportSource(h)))
+	for _, t := range tables {
+		fmt.Fprintf(&b, "<h2>%s</h2>\n", esc(t.title))
+		if len(t.rows) > 0 {
+			b.WriteString("<table>\n<tr>")
+			for _, c := range t.columns {
+				fmt.Fprintf(&b, "<th>%s</th>", esc(c))
+			}
+			b.WriteString("</tr>\n")
+			for _, r := range t.rows {
+				b.WriteString("<tr>")
+				for _, c := range r {
+					fmt.Fprintf(&b, "<td>%s</td>", esc(c))
+				}
+				b.WriteString("</tr>\n")
+			}
+			b.WriteString("</table>\n")
+		}
+		fmt.Fprintf(&b, "<p>%s</p>\n", esc(t.total))
+	}
+	b.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(out, b.String())
+	return err
+}
@@ -0,0 +1,147 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Advisory is an advisory as an entry of the matchup, two advisories are the
+// same when they have the same id, updated date and package set
+type Advisory struct {
+	Update
+}
+
+// PackageSet gives the sorted NEVRAs of the packages of the advisory
+func (a Advisory) PackageSet() []string {
+	seen := make(map[string]bool)
+	var set []string
+	for _, p := range a.Packages {
+		if n := p.NEVRA(); !seen[n] {
+			seen[n] = true
+			set = append(set, n)
+		}
+	}
+	sort.Strings(set)
+	return set
+}
+
+// packageDigest is the sha256 of the package set
+func (a Advisory) packageDigest() string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(a.PackageSet(), "\n"))))
+}
+
+func (a Advisory) Key() string {
+	return strings.Join([]string{"advisory", a.ID, a.Updated.Date, a.packageDigest()}, "\x00")
+}
+
+// FileSize is zero as an advisory is not a file to download
+func (a Advisory) FileSize() uint64 { return 0 }
+
+// Entry gives the id as the name, with the sha256 of the package set as the
+// checksum
+func (a Advisory) Entry() Entry {
+	return Entry{Kind: "advisory", Name: a.ID, NEVRA: a.ID,
+		ChecksumType: "sha256", Checksum: a.packageDigest()}
+}
+
+// AdvisoryList gives the advisories of the repository as entries, which are
+// only known when it was loaded with FieldAdvisories
+func (r *Repo) AdvisoryList() []Matchable {
+	var list []Matchable
+	if r == nil {
+		return list
+	}
+	for _, u := range r.Updates {
+		list = append(list, Advisory{u})
+	}
+	return list
+}
+
+// AdvisoryChange is an advisory which is in both repositories but was changed,
+// with the packages added to and removed from it
+type AdvisoryChange struct {
+	Old, New        Advisory
+	PackagesAdded   []string
+	PackagesRemoved []string
+}
+
+// AdvisoryResult is the errata diff, the advisories with the same id in both
+// repositories which were changed are paired up in Modified rather than being
+// both added and removed
+type AdvisoryResult struct {
+	Added    []Matchable
+	Removed  []Matchable
+	Common   []Matchable
+	Modified []AdvisoryChange
+}
+
+// DiffAdvisories compares the advisories of the old and new repositories,
+// either may be nil to stand in for an empty repository
+func DiffAdvisories(oldRepo, newRepo *Repo) (*AdvisoryResult, error) {
+	r, err := Diff(&Repo{Packages: oldRepo.AdvisoryList()}, &Repo{Packages: newRepo.AdvisoryList()}, DiffOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	removedByID := make(map[string]int)
+	for i, m := range r.Removed {
+		removedByID[m.(Advisory).ID] = i
+	}
+	ret := &AdvisoryResult{Common: r.Common}
+	paired := make(map[int]bool)
+	for _, m := range r.Added {
+		a := m.(Advisory)
+		i, ok := removedByID[a.ID]
+		if !ok || paired[i] {
+			ret.Added = append(ret.Added, m)
+			continue
+		}
+		paired[i] = true
+		c := AdvisoryChange{Old: r.Removed[i].(Advisory), New: a}
+		c.PackagesAdded, c.PackagesRemoved = setDiff(c.Old.PackageSet(), c.New.PackageSet())
+		ret.Modified = append(ret.Modified, c)
+	}
+	for i, m := range r.Removed {
+		if !paired[i] {
+			ret.Removed = append(ret.Removed, m)
+		}
+	}
+	return ret, nil
+}
+
+// setDiff gives the values only in b and only in a, of two sorted lists
+func setDiff(a, b []string) (added, removed []string) {
+	inA := make(map[string]bool, len(a))
+	for _, v := range a {
+		inA[v] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, v := range b {
+		inB[v] = true
+		if !inA[v] {
+			added = append(added, v)
+		}
+	}
+	for _, v := range a {
+		if !inB[v] {
+			removed = append(removed, v)
+		}
+	}
+	return
+}
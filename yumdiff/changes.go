@@ -0,0 +1,168 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import "fmt"
+
+// Class is the kind of change of an entry between the old and new repository
+type Class int
+
+const (
+	// ClassNew is an entry only in the new repository
+	ClassNew Class = iota
+	// ClassRemoved is an entry only in the old repository
+	ClassRemoved
+	// ClassUpgraded is a newer EVR of the same name and arch
+	ClassUpgraded
+	// ClassDowngraded is an older EVR of the same name and arch
+	ClassDowngraded
+	// ClassRebuilt is the same NEVRA with a different checksum, such as a
+	// re-signed or rebuilt RPM
+	ClassRebuilt
+	// ClassMoved is the same checksum at a different href
+	ClassMoved
+)
+
+// Classes lists every class in the order they are reported
+var Classes = []Class{ClassNew, ClassRemoved, ClassUpgraded, ClassDowngraded, ClassRebuilt, ClassMoved}
+
+var classNames = []string{"new", "removed", "upgraded", "downgraded", "rebuilt", "moved"}
+
+func (c Class) String() string {
+	if c >= 0 && int(c) < len(classNames) {
+		return classNames[c]
+	}
+	return fmt.Sprintf("Class(%d)", int(c))
+}
+
+// ParseClass returns the class by the name given by String
+func ParseClass(name string) (Class, error) {
+	for i, n := range classNames {
+		if n == name {
+			return Class(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown change class %q", name)
+}
+
+// Change is a classified entry, Old is nil for a new entry and New is nil for
+// a removed one, the rest have both sides
+type Change struct {
+	Class Class
+	Old   Matchable
+	New   Matchable
+}
+
+// Classify pairs up the added and removed entries of the Result.  The entries
+// with the same checksum are paired first as moved, then the same kind and
+// NEVRA as rebuilt, then the newest of every kind, name and arch as upgraded
+// or downgraded; the rest are new or removed.  The names are only known when
+// the entries are loaded with FieldNEVRA.  The changes with a new entry come
+// first in the order of Added, followed by the removed ones in the order of
+// Removed.
+func (r *Result) Classify() []Change {
+	added := make([]Entry, len(r.Added))
+	for i, m := range r.Added {
+		added[i] = m.Entry()
+	}
+	removed := make([]Entry, len(r.Removed))
+	for i, m := range r.Removed {
+		removed[i] = m.Entry()
+	}
+
+	// pairedWith maps the index of an added entry to the change made of it
+	pairedWith := make(map[int]Change)
+	removedPaired := make([]bool, len(removed))
+
+	// pairBy pairs every unpaired added entry with the first unpaired removed
+	// entry of the same key, an empty key is never paired
+	pairBy := func(class Class, key func(e Entry) string) {
+		index := make(map[string][]int)
+		for i, e := range removed {
+			if k := key(e); k != "" && !removedPaired[i] {
+				index[k] = append(index[k], i)
+			}
+		}
+		for i, e := range added {
+			if _, ok := pairedWith[i]; ok {
+				continue
+			}
+			k := key(e)
+			if k == "" || len(index[k]) == 0 {
+				continue
+			}
+			iOld := index[k][0]
+			index[k] = index[k][1:]
+			pairedWith[i] = Change{Class: class, Old: r.Removed[iOld], New: r.Added[i]}
+			removedPaired[iOld] = true
+		}
+	}
+	pairBy(ClassMoved, func(e Entry) string {
+		return e.Kind + "\x00" + e.ChecksumType + "\x00" + e.Checksum
+	})
+	pairBy(ClassRebuilt, func(e Entry) string {
+		if e.Name == "" {
+			return ""
+		}
+		return e.Kind + "\x00" + e.NEVRA
+	})
+
+	// The newest unpaired entries of every kind, name and arch on both sides
+	newest := func(list []Entry, paired func(i int) bool) map[string]int {
+		idx := make(map[string]int)
+		for i, e := range list {
+			if e.Name == "" || paired(i) {
+				continue
+			}
+			id := e.Kind + "\x00" + e.Name + "\x00" + e.Arch
+			if j, ok := idx[id]; !ok || CompareEVR(e.EVR(), list[j].EVR()) > 0 {
+				idx[id] = i
+			}
+		}
+		return idx
+	}
+	newestRemoved := newest(removed, func(i int) bool { return removedPaired[i] })
+	newestAdded := newest(added, func(i int) bool { _, ok := pairedWith[i]; return ok })
+	for id, iNew := range newestAdded {
+		iOld, ok := newestRemoved[id]
+		if !ok {
+			continue
+		}
+		class := ClassUpgraded
+		switch CompareEVR(added[iNew].EVR(), removed[iOld].EVR()) {
+		case 0:
+			continue
+		case -1:
+			class = ClassDowngraded
+		}
+		pairedWith[iNew] = Change{Class: class, Old: r.Removed[iOld], New: r.Added[iNew]}
+		removedPaired[iOld] = true
+	}
+
+	changes := make([]Change, 0, len(added)+len(removed)-len(pairedWith))
+	for i, m := range r.Added {
+		if c, ok := pairedWith[i]; ok {
+			changes = append(changes, c)
+		} else {
+			changes = append(changes, Change{Class: ClassNew, New: m})
+		}
+	}
+	for i, m := range r.Removed {
+		if !removedPaired[i] {
+			changes = append(changes, Change{Class: ClassRemoved, Old: m})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,131 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testDelta builds a delta from the old version to the new version
+func testDelta(name, ver, oldVer string) DeltaPackage {
+	var d DeltaPackage
+	d.Name, d.Arch, d.Epoch, d.Version, d.Release = name, "x86_64", "0", ver, "1"
+	d.Delta.Oldepoch, d.Delta.Oldversion, d.Delta.Oldrelease = "0", oldVer, "1"
+	d.Delta.Filename = fmt.Sprintf("drpms/%s-%s_%s-1.x86_64.drpm", name, oldVer, ver)
+	d.Delta.Size = "100"
+	d.Delta.Checksum.Type = "sha256"
+	d.Delta.Checksum.Text = fmt.Sprintf("%x", d.Delta.Filename)
+	return d
+}
+
+// moved returns the package at another href
+func moved(p Package) Package {
+	p.Location.Href = "moved/" + p.Location.Href
+	return p
+}
+
+// rebuilt returns the package with another checksum
+func rebuilt(p Package) Package {
+	p.Checksum.Text = "rebuilt-" + p.Checksum.Text
+	return p
+}
+
+// noName returns the package as decoded without FieldNEVRA
+func noName(p Package) Package {
+	p.Name, p.Arch, p.Version = "", "", EVR{}
+	return p
+}
+
+func TestClassify(t *testing.T) {
+	a1, a2, a3, a4, a5 := testPackage("A", "1", "1", 1), testPackage("A", "2", "1", 2),
+		testPackage("A", "3", "1", 3), testPackage("A", "4", "1", 4), testPackage("A", "5", "1", 5)
+	b1, b2 := testPackage("B", "1", "1", 1), testPackage("B", "2", "1", 2)
+
+	tests := []struct {
+		name           string
+		added, removed []Matchable
+		want           []string
+	}{
+		{"new", []Matchable{a1}, nil,
+			[]string{"new  Packages/A-1-1.x86_64.rpm"}},
+		{"removed", nil, []Matchable{a1},
+			[]string{"removed Packages/A-1-1.x86_64.rpm "}},
+		{"upgraded", []Matchable{a2}, []Matchable{a1},
+			[]string{"upgraded Packages/A-1-1.x86_64.rpm Packages/A-2-1.x86_64.rpm"}},
+		{"downgraded", []Matchable{a1}, []Matchable{a2},
+			[]string{"downgraded Packages/A-2-1.x86_64.rpm Packages/A-1-1.x86_64.rpm"}},
+		{"rebuilt", []Matchable{rebuilt(a1)}, []Matchable{a1},
+			[]string{"rebuilt Packages/A-1-1.x86_64.rpm Packages/A-1-1.x86_64.rpm"}},
+		{"moved", []Matchable{moved(a1)}, []Matchable{a1},
+			[]string{"moved Packages/A-1-1.x86_64.rpm moved/Packages/A-1-1.x86_64.rpm"}},
+		{"moved before upgraded", []Matchable{a2, moved(a1)}, []Matchable{a1},
+			[]string{"new  Packages/A-2-1.x86_64.rpm", "moved Packages/A-1-1.x86_64.rpm moved/Packages/A-1-1.x86_64.rpm"}},
+		{"several builds", []Matchable{rebuilt(a2), a4, a5}, []Matchable{a1, a2, a3},
+			[]string{
+				"rebuilt Packages/A-2-1.x86_64.rpm Packages/A-2-1.x86_64.rpm",
+				"new  Packages/A-4-1.x86_64.rpm",
+				"upgraded Packages/A-3-1.x86_64.rpm Packages/A-5-1.x86_64.rpm",
+				"removed Packages/A-1-1.x86_64.rpm ",
+			}},
+		{"deltas and packages", []Matchable{testDelta("B", "2", "1"), b2, testDelta("A", "2", "1")},
+			[]Matchable{testDelta("B", "1", "0"), a1, b1},
+			[]string{
+				"upgraded drpms/B-0_1-1.x86_64.drpm drpms/B-1_2-1.x86_64.drpm",
+				"upgraded Packages/B-1-1.x86_64.rpm Packages/B-2-1.x86_64.rpm",
+				"new  drpms/A-1_2-1.x86_64.drpm",
+				"removed Packages/A-1-1.x86_64.rpm ",
+			}},
+		{"no names", []Matchable{noName(a2), moved(noName(b1))}, []Matchable{noName(a1), noName(b1)},
+			[]string{
+				"new  Packages/A-2-1.x86_64.rpm",
+				"moved Packages/B-1-1.x86_64.rpm moved/Packages/B-1-1.x86_64.rpm",
+				"removed Packages/A-1-1.x86_64.rpm ",
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Result{Added: tt.added, Removed: tt.removed}
+			var got []string
+			for _, c := range r.Classify() {
+				var hrefs []string
+				for _, m := range []Matchable{c.Old, c.New} {
+					if m == nil {
+						hrefs = append(hrefs, "")
+					} else {
+						hrefs = append(hrefs, m.Entry().Href)
+					}
+				}
+				got = append(got, c.Class.String()+" "+strings.Join(hrefs, " "))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseClass(t *testing.T) {
+	for _, c := range Classes {
+		if got, err := ParseClass(c.String()); err != nil || got != c {
+			t.Errorf("ParseClass(%q) = %v, %v", c.String(), got, err)
+		}
+	}
+	if _, err := ParseClass("unknown"); err == nil {
+		t.Errorf("no error for an unknown class")
+	}
+}
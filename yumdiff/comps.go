@@ -0,0 +1,311 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Comps is the comps.xml file listed as the group or group_gz data of the
+// repomd.xml, holding the package groups used by installers and kickstarts
+type Comps struct {
+	Groups       []Group       `xml:"group"`
+	Environments []Environment `xml:"environment"`
+	Categories   []Category    `xml:"category"`
+}
+
+// Group is a package group, such as core or base
+type Group struct {
+	ID       string         `xml:"id"`
+	Names    []CompsName    `xml:"name"`
+	Packages []GroupPackage `xml:"packagelist>packagereq"`
+}
+
+// GroupPackage is a package of a group, the type is mandatory, default,
+// optional or conditional
+type GroupPackage struct {
+	Name     string `xml:",chardata"`
+	Type     string `xml:"type,attr"`
+	Requires string `xml:"requires,attr"`
+}
+
+// Environment is a set of groups, with further optional groups
+type Environment struct {
+	ID      string      `xml:"id"`
+	Names   []CompsName `xml:"name"`
+	Groups  []string    `xml:"grouplist>groupid"`
+	Options []string    `xml:"optionlist>groupid"`
+}
+
+// Category is a set of groups for display
+type Category struct {
+	ID     string      `xml:"id"`
+	Names  []CompsName `xml:"name"`
+	Groups []string    `xml:"grouplist>groupid"`
+}
+
+// CompsName is a name of a comps entry, with the language of the translations
+type CompsName struct {
+	Text string `xml:",chardata"`
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+}
+
+// compsName gives the untranslated name
+func compsName(names []CompsName) string {
+	for _, n := range names {
+		if n.Lang == "" {
+			return n.Text
+		}
+	}
+	return ""
+}
+
+// Name gives the untranslated name of the group
+func (g Group) Name() string { return compsName(g.Names) }
+
+// Name gives the untranslated name of the environment
+func (e Environment) Name() string { return compsName(e.Names) }
+
+// Name gives the untranslated name of the category
+func (c Category) Name() string { return compsName(c.Names) }
+
+// readComps reads in the comps file
+func readComps(ctx context.Context, fileName string, d *RepomdData) (*Comps, error) {
+	var c *Comps
+	err := streamFile(ctx, fileName, d, func(decoder *xml.Decoder, se xml.StartElement) error {
+		// The root element is decoded as a whole
+		c = &Comps{}
+		if err := decoder.DecodeElement(c, &se); err != nil {
+			return decodeError(fileName, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("%w, no comps element in %s", ErrMalformed, fileName)
+	}
+	return c, nil
+}
+
+// Member is a member of a group (a package), environment or category (a
+// group), the type is the packagereq type of a package, or either group or
+// option for the groups
+type Member struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// MemberChange is a member which is in both but with a different type, such
+// as a package moved from optional to default
+type MemberChange struct {
+	Name    string `json:"name"`
+	OldType string `json:"oldType"`
+	NewType string `json:"newType"`
+}
+
+// CompsChange is the change of the members of a group, environment or
+// category between the old and new comps
+type CompsChange struct {
+	Kind    string         `json:"kind"`
+	ID      string         `json:"id"`
+	Name    string         `json:"name,omitempty"`
+	Status  string         `json:"status"`
+	Added   []Member       `json:"added,omitempty"`
+	Removed []Member       `json:"removed,omitempty"`
+	Changed []MemberChange `json:"changed,omitempty"`
+}
+
+// compsEntry is a group, environment or category with its members
+type compsEntry struct {
+	kind, id, name string
+	members        []Member
+}
+
+// entries lists every group, environment and category of the comps
+func (c *Comps) entries() (list []compsEntry) {
+	if c == nil {
+		return
+	}
+	for _, g := range c.Groups {
+		e := compsEntry{kind: "group", id: g.ID, name: g.Name()}
+		for _, p := range g.Packages {
+			e.members = append(e.members, Member{Name: p.Name, Type: p.Type})
+		}
+		list = append(list, e)
+	}
+	for _, env := range c.Environments {
+		e := compsEntry{kind: "environment", id: env.ID, name: env.Name()}
+		for _, g := range env.Groups {
+			e.members = append(e.members, Member{Name: g, Type: "group"})
+		}
+		for _, g := range env.Options {
+			e.members = append(e.members, Member{Name: g, Type: "option"})
+		}
+		list = append(list, e)
+	}
+	for _, cat := range c.Categories {
+		e := compsEntry{kind: "category", id: cat.ID, name: cat.Name()}
+		for _, g := range cat.Groups {
+			e.members = append(e.members, Member{Name: g, Type: "group"})
+		}
+		list = append(list, e)
+	}
+	return
+}
+
+// DiffComps compares the groups, environments and categories of the old and
+// new comps, either may be nil.  Only the entries which were added, removed
+// or changed are returned, the status is one of these, in the order of the
+// new comps followed by the removed ones.
+func DiffComps(oldComps, newComps *Comps) []CompsChange {
+	oldEntries := make(map[string]compsEntry)
+	for _, e := range oldComps.entries() {
+		oldEntries[e.kind+"\x00"+e.id] = e
+	}
+
+	var changes []CompsChange
+	seen := make(map[string]bool)
+	for _, e := range newComps.entries() {
+		key := e.kind + "\x00" + e.id
+		seen[key] = true
+		c := CompsChange{Kind: e.kind, ID: e.id, Name: e.name, Status: "changed"}
+		old, ok := oldEntries[key]
+		if !ok {
+			c.Status = "added"
+		}
+		c.Added, c.Removed, c.Changed = diffMembers(old.members, e.members)
+		if ok && len(c.Added)+len(c.Removed)+len(c.Changed) == 0 {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	for _, e := range oldComps.entries() {
+		if seen[e.kind+"\x00"+e.id] {
+			continue
+		}
+		c := CompsChange{Kind: e.kind, ID: e.id, Name: e.name, Status: "removed"}
+		c.Added, c.Removed, c.Changed = diffMembers(e.members, nil)
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// diffMembers compares the member lists by name, each result is sorted
+func diffMembers(oldMembers, newMembers []Member) (added, removed []Member, changed []MemberChange) {
+	oldTypes := make(map[string]string, len(oldMembers))
+	for _, m := range oldMembers {
+		oldTypes[m.Name] = m.Type
+	}
+	newTypes := make(map[string]string, len(newMembers))
+	for _, m := range newMembers {
+		newTypes[m.Name] = m.Type
+		if t, ok := oldTypes[m.Name]; !ok {
+			added = append(added, m)
+		} else if t != m.Type {
+			changed = append(changed, MemberChange{Name: m.Name, OldType: t, NewType: m.Type})
+		}
+	}
+	for _, m := range oldMembers {
+		if _, ok := newTypes[m.Name]; !ok {
+			removed = append(removed, m)
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].Name < added[j].Name })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Name < removed[j].Name })
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Name < changed[j].Name })
+	return
+}
+
+// GroupPackageNames gives the names of the packages of the groups, groups
+// which are not in the comps are skipped
+func (c *Comps) GroupPackageNames(ids []string) map[string]bool {
+	names := make(map[string]bool)
+	if c == nil {
+		return names
+	}
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	for _, g := range c.Groups {
+		if !want[g.ID] {
+			continue
+		}
+		for _, p := range g.Packages {
+			names[p.Name] = true
+		}
+	}
+	return names
+}
+
+// InGroups returns copies of the old and new repositories with only the
+// entries of the packages in the groups, deltas are kept by the name of the
+// package they build.  The members are taken from the comps of both sides, so
+// a package which joined a group is still matched up with the old build it
+// replaces.  It is an error when neither side has comps or a group is in
+// neither comps, rather than keeping no packages.  The repositories need to be
+// loaded with FieldNEVRA and FieldGroups.
+func InGroups(oldRepo, newRepo *Repo, ids []string) (*Repo, *Repo, error) {
+	names := make(map[string]bool)
+	found := make(map[string]bool)
+	var haveComps bool
+	for _, r := range []*Repo{oldRepo, newRepo} {
+		if r == nil || r.Comps == nil {
+			continue
+		}
+		haveComps = true
+		for _, g := range r.Comps.Groups {
+			found[g.ID] = true
+		}
+		for name := range r.Comps.GroupPackageNames(ids) {
+			names[name] = true
+		}
+	}
+	if !haveComps {
+		return nil, nil, fmt.Errorf("No comps groups in either repository to find the groups %s in", strings.Join(ids, ", "))
+	}
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("Unknown groups %s, not in the comps of either repository", strings.Join(missing, ", "))
+	}
+	return oldRepo.withNames(names), newRepo.withNames(names), nil
+}
+
+// withNames returns a copy of the repository with only the entries of the
+// packages with the names
+func (r *Repo) withNames(names map[string]bool) *Repo {
+	if r == nil {
+		return nil
+	}
+	ret := *r
+	ret.Packages = []Matchable{}
+	for _, m := range r.Packages {
+		if names[m.Entry().Name] {
+			ret.Packages = append(ret.Packages, m)
+		}
+	}
+	return &ret
+}
@@ -0,0 +1,103 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func testComps(core ...string) *Comps {
+	g := Group{ID: "core"}
+	for _, name := range core {
+		g.Packages = append(g.Packages, GroupPackage{Name: name, Type: "mandatory"})
+	}
+	return &Comps{Groups: []Group{g, {ID: "base", Packages: []GroupPackage{{Name: "other"}}}}}
+}
+
+func TestInGroups(t *testing.T) {
+	a := testPackage("a", "1.0", "1", 1)
+	b := testPackage("b", "1.0", "1", 1)
+	other := testPackage("other", "1.0", "1", 1)
+	pkgs := []Matchable{a, b, other}
+
+	tests := []struct {
+		name                 string
+		oldComps, newComps   *Comps
+		added, removed, both []string
+	}{
+		// b joined core while already on the mirror
+		{"joined", testComps("a"), testComps("a", "b"), nil, nil, []string{"a", "b"}},
+		// b left core, it is still kept from the old comps
+		{"left", testComps("a", "b"), testComps("a"), nil, nil, []string{"a", "b"}},
+		{"no old comps", nil, testComps("a", "b"), nil, nil, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldRepo := &Repo{Packages: pkgs, Comps: tt.oldComps}
+			newRepo := &Repo{Packages: pkgs, Comps: tt.newComps}
+			oldRepo, newRepo, err := InGroups(oldRepo, newRepo, []string{"core"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := Diff(oldRepo, newRepo, DiffOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []struct {
+				got  []Matchable
+				want []string
+			}{{r.Added, tt.added}, {r.Removed, tt.removed}, {r.Common, tt.both}} {
+				var names []string
+				for _, m := range c.got {
+					names = append(names, m.Entry().Name)
+				}
+				sort.Strings(names)
+				if !reflect.DeepEqual(names, c.want) {
+					t.Errorf("got %q, want %q", names, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestInGroupsErrors(t *testing.T) {
+	pkgs := []Matchable{testPackage("a", "1.0", "1", 1)}
+	tests := []struct {
+		name             string
+		oldRepo, newRepo *Repo
+		ids              []string
+		wantErr          string
+	}{
+		{"no comps", &Repo{Packages: pkgs}, &Repo{Packages: pkgs}, []string{"core"}, "No comps groups"},
+		{"no repos", nil, nil, []string{"core"}, "No comps groups"},
+		{"unknown group", &Repo{Packages: pkgs}, &Repo{Packages: pkgs, Comps: testComps("a")}, []string{"core", "cor"}, "Unknown groups cor,"},
+		{"group on the old side", &Repo{Packages: pkgs, Comps: testComps("a")}, nil, []string{"core", "base"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := InGroups(tt.oldRepo, tt.newRepo, tt.ids)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
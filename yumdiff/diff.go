@@ -0,0 +1,133 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import "fmt"
+
+// DiffOptions selects the package filtering and retention applied before the
+// matchup, the packages need to be loaded with FieldNEVRA for these to work or
+// Diff returns an error
+type DiffOptions struct {
+	// Filter drops the entries of both repositories which do not pass, when
+	// set, before any of the retention below
+	Filter *Filter
+
+	// LatestNew keeps only the newest build of every package name and arch in
+	// the new repository
+	LatestNew bool
+	// LatestN keeps only the N newest builds of every package name and arch in
+	// the new repository, 0 keeps all.  The old repository is left whole so the
+	// builds which fall out of the window are listed as removed.
+	LatestN int
+}
+
+// Result holds the entries only in the new repository (Added), only in the old
+// repository (Removed) and in both (Common, taken from the new repository)
+type Result struct {
+	Added   []Matchable
+	Removed []Matchable
+	Common  []Matchable
+}
+
+// Diff compares the old and new repositories, either may be nil to stand in
+// for an empty repository.  The repositories are not modified.
+func Diff(oldRepo, newRepo *Repo, opts DiffOptions) (*Result, error) {
+	if opts.LatestN < 0 {
+		return nil, fmt.Errorf("Invalid LatestN of %d", opts.LatestN)
+	}
+
+	var newPackages, oldPackages []Matchable
+	if newRepo != nil {
+		newPackages = newRepo.Packages
+	}
+	if oldRepo != nil {
+		oldPackages = oldRepo.Packages
+	}
+
+	if opts.Filter != nil || opts.LatestNew || opts.LatestN > 0 {
+		for _, pkgs := range [][]Matchable{newPackages, oldPackages} {
+			if err := checkNames(pkgs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.Filter != nil {
+		newPackages = FilterEntries(newPackages, opts.Filter)
+		oldPackages = FilterEntries(oldPackages, opts.Filter)
+	}
+	if opts.LatestNew {
+		newPackages = KeepLatest(newPackages, 1)
+	}
+	if opts.LatestN > 0 {
+		newPackages = KeepLatest(newPackages, opts.LatestN)
+	}
+
+	newMatched, oldMatched := matchup(newPackages, oldPackages)
+
+	r := &Result{}
+	for iNew, pNew := range newPackages {
+		if newMatched[iNew] == 0 {
+			// This package was not seen in OLD
+			r.Added = append(r.Added, pNew)
+		} else {
+			// This package was seen in BOTH
+			r.Common = append(r.Common, pNew)
+		}
+	}
+	for iOld, pOld := range oldPackages {
+		if oldMatched[iOld] == 0 {
+			// This package was not seen in NEW
+			r.Removed = append(r.Removed, pOld)
+		}
+	}
+	return r, nil
+}
+
+// checkNames makes sure the packages were decoded with their names, without
+// them the filter and retention would treat every package alike
+func checkNames(pkgs []Matchable) error {
+	for _, m := range pkgs {
+		if p, ok := m.(Package); ok && p.Name == "" {
+			return fmt.Errorf("No name decoded for %s, the packages need to be loaded with FieldNEVRA to filter or keep the latest", p.Location.Href)
+		}
+	}
+	return nil
+}
+
+// matchup flags the entries which are found in both the new and the old lists,
+// the old list is indexed by key once so the lookups run in linear time
+func matchup(newPackages, oldPackages []Matchable) (newMatched, oldMatched []int8) {
+	// initialized with zeros
+	newMatched = make([]int8, len(newPackages))
+	oldMatched = make([]int8, len(oldPackages))
+
+	oldIndex := make(map[string]int, len(oldPackages))
+	for iOld, pOld := range oldPackages {
+		// Only the first occurrence is kept, as this is the one that would have
+		// been found first by a linear search
+		if _, ok := oldIndex[pOld.Key()]; !ok {
+			oldIndex[pOld.Key()] = iOld
+		}
+	}
+
+	for iNew, pNew := range newPackages {
+		if iOld, ok := oldIndex[pNew.Key()]; ok {
+			newMatched[iNew] = 1
+			oldMatched[iOld] = 1
+		}
+	}
+	return
+}
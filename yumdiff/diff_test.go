@@ -0,0 +1,192 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testPackage builds a package entry, the checksum is derived from the NEVRA
+// so that the same build has the same key in both lists
+func testPackage(name, ver, rel string, build int64) Package {
+	var p Package
+	p.Name, p.Arch = name, "x86_64"
+	p.Version = EVR{Epoch: "0", Ver: ver, Rel: rel}
+	p.Checksum.Type = "sha256"
+	p.Checksum.Text = fmt.Sprintf("%x", p.NEVRA())
+	p.Size.Package = "1024"
+	p.Location.Href = "Packages/" + p.NEVRA() + ".rpm"
+	p.Time.Build = build
+	return p
+}
+
+// generateLists builds an old and a new list of n entries each, the new list
+// drops every tenth old entry and adds as many new ones in their place
+func generateLists(n int) (oldPackages, newPackages []Matchable) {
+	for i := 0; i < n; i++ {
+		oldPackages = append(oldPackages, testPackage(fmt.Sprintf("pkg%d", i), "1.0", "1", 0))
+		if i%10 == 0 {
+			newPackages = append(newPackages, testPackage(fmt.Sprintf("pkg%d", i), "1.1", "1", 0))
+		} else {
+			newPackages = append(newPackages, oldPackages[i])
+		}
+	}
+	return
+}
+
+// pairwiseMatchup is the nested loop matchup used before the old list was
+// indexed, the first old entry with a matching key wins
+func pairwiseMatchup(newPackages, oldPackages []Matchable) (newMatched, oldMatched []int8) {
+	newMatched = make([]int8, len(newPackages))
+	oldMatched = make([]int8, len(oldPackages))
+
+	newKeys := make([]string, len(newPackages))
+	for i, m := range newPackages {
+		newKeys[i] = m.Key()
+	}
+	oldKeys := make([]string, len(oldPackages))
+	for i, m := range oldPackages {
+		oldKeys[i] = m.Key()
+	}
+
+	for iNew := range newPackages {
+		for iOld := range oldPackages {
+			if newKeys[iNew] == oldKeys[iOld] {
+				newMatched[iNew] = 1
+				oldMatched[iOld] = 1
+				break
+			}
+		}
+	}
+	return
+}
+
+func TestMatchupSameAsPairwise(t *testing.T) {
+	oldPackages, newPackages := generateLists(1000)
+
+	// Duplicate keys on both sides
+	dup := testPackage("dup", "1.0", "1", 0)
+	oldPackages = append(oldPackages, dup, dup, testPackage("olddup", "1.0", "1", 0))
+	oldPackages = append(oldPackages, oldPackages[len(oldPackages)-1])
+	newPackages = append(newPackages, dup, testPackage("newdup", "1.0", "1", 0), dup)
+	newPackages = append(newPackages, newPackages[len(newPackages)-2])
+
+	newIndexed, oldIndexed := matchup(newPackages, oldPackages)
+	newPairwise, oldPairwise := pairwiseMatchup(newPackages, oldPackages)
+	if !reflect.DeepEqual(newIndexed, newPairwise) {
+		t.Errorf("new matches differ between the indexed and pairwise matchup")
+	}
+	if !reflect.DeepEqual(oldIndexed, oldPairwise) {
+		t.Errorf("old matches differ between the indexed and pairwise matchup")
+	}
+
+	var added, removed, common int
+	for _, m := range newIndexed {
+		if m == 0 {
+			added++
+		} else {
+			common++
+		}
+	}
+	for _, m := range oldIndexed {
+		if m == 0 {
+			removed++
+		}
+	}
+	// 100 rebuilt, plus both newdup; 100 replaced, the second dup and both olddup
+	if added != 102 || removed != 103 || common != 902 {
+		t.Errorf("got %d added, %d removed, %d common", added, removed, common)
+	}
+}
+
+func TestDiffLatestNRemoved(t *testing.T) {
+	var oldRepo, newRepo Repo
+	for i := 1; i <= 5; i++ {
+		p := testPackage("A", fmt.Sprint(i), "1", int64(i))
+		if i <= 4 {
+			oldRepo.Packages = append(oldRepo.Packages, p)
+		}
+		newRepo.Packages = append(newRepo.Packages, p)
+	}
+
+	r, err := Diff(&oldRepo, &newRepo, DiffOptions{LatestN: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Removed {
+		got = append(got, m.Entry().NEVRA)
+	}
+	want := []string{"A-1-1.x86_64", "A-2-1.x86_64"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("removed %q, want %q", got, want)
+	}
+	if len(r.Added) != 1 || r.Added[0].Entry().NEVRA != "A-5-1.x86_64" {
+		t.Errorf("unexpected added %v", r.Added)
+	}
+	if len(r.Common) != 2 {
+		t.Errorf("got %d common, want 2", len(r.Common))
+	}
+}
+
+func BenchmarkMatchupIndexed(b *testing.B) {
+	oldPackages, newPackages := generateLists(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matchup(newPackages, oldPackages)
+	}
+}
+
+func BenchmarkMatchupPairwise(b *testing.B) {
+	oldPackages, newPackages := generateLists(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pairwiseMatchup(newPackages, oldPackages)
+	}
+}
+
+func TestDiffNeedsNames(t *testing.T) {
+	var repo Repo
+	for i := 1; i <= 5; i++ {
+		p := testPackage("A", fmt.Sprint(i), "1", int64(i))
+		// As decoded without FieldNEVRA
+		p.Name, p.Arch, p.Version, p.Time.Build = "", "", EVR{}, 0
+		repo.Packages = append(repo.Packages, p)
+	}
+
+	tests := []struct {
+		name    string
+		opts    DiffOptions
+		wantErr bool
+	}{
+		{"plain", DiffOptions{}, false},
+		{"latestNew", DiffOptions{LatestNew: true}, true},
+		{"latestN", DiffOptions{LatestN: 2}, true},
+		{"filter", DiffOptions{Filter: &Filter{Arch: []string{"x86_64"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Diff(nil, &repo, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && len(r.Added) != 5 {
+				t.Errorf("got %d added, want 5", len(r.Added))
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"errors"
+	"strings"
+)
+
+// The errors returned are wrapped with the name of the file, use errors.Is to
+// test for these.
+var (
+	// ErrChecksumMismatch is a file which does not match the checksum listed in
+	// the repomd.xml or metalink
+	ErrChecksumMismatch = errors.New("Checksum mismatch")
+	// ErrPackageCountMismatch is a primary.xml where the packages attribute
+	// does not agree with the number of packages in the file
+	ErrPackageCountMismatch = errors.New("XML Packages count does not match the number of Packages")
+	// ErrEmptyRepo is a package list without any packages
+	ErrEmptyRepo = errors.New("No packages found")
+	// ErrRepomdMissing is a repodata/ dir or URL without a repomd.xml
+	ErrRepomdMissing = errors.New("Could not open repomd.xml")
+	// ErrMalformed is a metadata file which could not be decompressed or decoded
+	ErrMalformed = errors.New("Malformed metadata")
+	// ErrUnsupportedCompression is a metadata file compressed with a format
+	// which cannot be read, such as zchunk
+	ErrUnsupportedCompression = errors.New("Unsupported compression")
+	// ErrSignature is a repomd.xml signature which is missing or does not verify
+	ErrSignature = errors.New("Signature verification failed")
+	// ErrNetwork is an HTTP request which failed to connect or complete
+	ErrNetwork = errors.New("Network error")
+)
+
+// mirrorsError holds the errors from every mirror which was tried
+type mirrorsError []error
+
+func (e mirrorsError) Error() string {
+	var errs []string
+	for _, err := range e {
+		errs = append(errs, err.Error())
+	}
+	return "No working mirrors found: " + strings.Join(errs, "; ")
+}
+
+func (e mirrorsError) Unwrap() []error { return e }
@@ -0,0 +1,133 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Client is used for all the HTTP requests, the timeouts are on the connection
+// and headers only, as a large primary file can take well over a minute to
+// come down from a slow mirror
+var Client = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+// IsURL determines if the file name is an HTTP(S) URL rather than a local path
+func IsURL(fileName string) bool {
+	return strings.HasPrefix(fileName, "http://") || strings.HasPrefix(fileName, "https://")
+}
+
+// joinURL appends a relative path onto a base URL
+func joinURL(base, rel string) string {
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(rel, "/")
+}
+
+// JoinPath joins a file name onto a local path or URL
+func JoinPath(dir, fileName string) string {
+	if IsURL(dir) {
+		return joinURL(dir, fileName)
+	}
+	return path.Join(dir, fileName)
+}
+
+// IsDirectory determines if a file represented
+// by `path` is a directory or not
+func IsDirectory(path string) (exist bool, isdir bool) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return false, false
+	}
+	return true, fileInfo.IsDir()
+}
+
+// isMetadataFile determines if a URL points at a single metadata file, like a
+// primary.xml.gz, rather than a repository base or repodata/ dir
+func isMetadataFile(fileName string) bool {
+	u, err := url.Parse(fileName)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(path.Base(u.Path), ".xml")
+}
+
+// openRaw opens a local file or does an HTTP GET for a URL, the caller needs
+// to close the returned handle
+func openRaw(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	if !IsURL(fileName) {
+		return os.Open(fileName)
+	}
+
+	Logger.Println("Fetching", fileName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileName, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w fetching %s: %w", ErrNetwork, fileName, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return &networkReader{resp.Body, fileName}, nil
+	case http.StatusNotFound, http.StatusGone:
+		resp.Body.Close()
+		return nil, fmt.Errorf("Error fetching %s: %s: %w", fileName, resp.Status, fs.ErrNotExist)
+	}
+	resp.Body.Close()
+	return nil, fmt.Errorf("%w fetching %s: %s", ErrNetwork, fileName, resp.Status)
+}
+
+// networkReader marks the errors from reading an HTTP response body as network
+// errors
+type networkReader struct {
+	io.ReadCloser
+	fileName string
+}
+
+func (n *networkReader) Read(p []byte) (int, error) {
+	c, err := n.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w reading %s: %w", ErrNetwork, n.fileName, err)
+	}
+	return c, err
+}
+
+// readAll reads in the whole contents of a local file or URL
+func readAll(ctx context.Context, fileName string) ([]byte, error) {
+	file, err := openRaw(ctx, fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
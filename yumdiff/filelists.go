@@ -0,0 +1,162 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+)
+
+// FileList is a <package> entry of the filelists.xml file, the pkgid is the
+// checksum of the package in the primary.xml file
+type FileList struct {
+	PkgID   string `xml:"pkgid,attr"`
+	Name    string `xml:"name,attr"`
+	Arch    string `xml:"arch,attr"`
+	Version EVR    `xml:"version"`
+	Files   []File `xml:"file"`
+}
+
+// File is a path shipped by a package, the type is empty for a plain file or
+// is dir or ghost
+type File struct {
+	Path string `xml:",chardata"`
+	Type string `xml:"type,attr"`
+}
+
+// NEVRA gives the name-[epoch:]version-release.arch of the package
+func (f FileList) NEVRA() string {
+	return f.Name + "-" + f.Version.String() + "." + f.Arch
+}
+
+// StreamFilelists reads in the filelists file and calls fn with each package
+// as it is decoded, when the repomd data entry is given the checksums are
+// verified.
+func StreamFilelists(ctx context.Context, fileName string, d *RepomdData, fn func(FileList) error) error {
+	return streamPackageElements(ctx, fileName, d, "filelists", fn)
+}
+
+// streamPackageElements decodes each <package> element of a filelists or
+// other file into T, the count is checked against the packages attribute of
+// the root element.
+func streamPackageElements[T any](ctx context.Context, fileName string, d *RepomdData, root string, fn func(T) error) error {
+	var count, expected int
+	var haveExpected bool
+	err := streamFile(ctx, fileName, d, func(decoder *xml.Decoder, se xml.StartElement) error {
+		switch se.Name.Local {
+		case root:
+			if v := attr(se, "packages"); v != "" {
+				expected, haveExpected = int(atoi(v)), true
+			}
+		case "package":
+			var p T
+			if err := decoder.DecodeElement(&p, &se); err != nil {
+				return decodeError(fileName, err)
+			}
+			count++
+			return fn(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if haveExpected && count != expected {
+		return fmt.Errorf("%w in %s", ErrPackageCountMismatch, fileName)
+	}
+	return nil
+}
+
+// HasFileLists reports if the repository has a filelists file to read from,
+// only a repodata/ dir can have one
+func (r *Repo) HasFileLists() bool {
+	return r != nil && r.Repomd.findData("filelists") != nil
+}
+
+// streamFilelists streams the filelists of the repository, the file is only
+// read when needed as the file lists of a whole repository are large
+func (r *Repo) streamFilelists(ctx context.Context, fn func(FileList) error) error {
+	d := r.Repomd.findData("filelists")
+	if d == nil {
+		return fmt.Errorf("No filelists in %s", r.Mirror)
+	}
+	return StreamFilelists(ctx, r.Repomd.dataFile(*d), d, fn)
+}
+
+// LoadFileLists reads in the file lists of the packages with the pkgids into
+// Repo.Files, the others are skipped over
+func (r *Repo) LoadFileLists(ctx context.Context, pkgids map[string]bool) error {
+	r.Files = make(map[string]FileList)
+	return r.streamFilelists(ctx, func(f FileList) error {
+		if pkgids[f.PkgID] {
+			r.Files[f.PkgID] = f
+		}
+		return nil
+	})
+}
+
+// FileList returns the files of the package, which are only known once they
+// were read in with LoadFileLists
+func (r *Repo) FileList(m Matchable) (FileList, bool) {
+	p, ok := m.(Package)
+	if !ok || r == nil {
+		return FileList{}, false
+	}
+	f, ok := r.Files[p.Checksum.Text]
+	return f, ok
+}
+
+// WhatProvides streams the filelists and returns the packages shipping a path
+// matching the pattern, sorted by NEVRA
+func (r *Repo) WhatProvides(ctx context.Context, p Pattern) (found []FileList, err error) {
+	err = r.streamFilelists(ctx, func(f FileList) error {
+		for _, file := range f.Files {
+			if p.Match(file.Path) {
+				found = append(found, f)
+				break
+			}
+		}
+		return nil
+	})
+	sort.Slice(found, func(i, j int) bool { return found[i].NEVRA() < found[j].NEVRA() })
+	return
+}
+
+// FileDiff compares the paths of two builds of a package, returning the
+// paths only in the new build and only in the old build, each sorted
+func FileDiff(oldFiles, newFiles FileList) (added, removed []string) {
+	inOld := make(map[string]bool, len(oldFiles.Files))
+	for _, f := range oldFiles.Files {
+		inOld[f.Path] = true
+	}
+	inNew := make(map[string]bool, len(newFiles.Files))
+	for _, f := range newFiles.Files {
+		inNew[f.Path] = true
+		if !inOld[f.Path] {
+			added = append(added, f.Path)
+		}
+	}
+	for _, f := range oldFiles.Files {
+		if !inNew[f.Path] {
+			removed = append(removed, f.Path)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return
+}
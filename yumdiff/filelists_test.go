@@ -0,0 +1,102 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testFilelists builds the filelists.xml of the packages, each shipping the
+// paths given for it
+func testFilelists(pkgs []Package, files [][]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<filelists xmlns="http://linux.duke.edu/metadata/filelists" packages="%d">
+`, len(pkgs))
+	for i, p := range pkgs {
+		fmt.Fprintf(&b, `<package pkgid="%s" name="%s" arch="%s">
+  <version epoch="%s" ver="%s" rel="%s"/>
+`, p.Checksum.Text, p.Name, p.Arch, p.Version.Epoch, p.Version.Ver, p.Version.Rel)
+		for _, f := range files[i] {
+			fmt.Fprintf(&b, "  <file>%s</file>\n", f)
+		}
+		b.WriteString("</package>\n")
+	}
+	b.WriteString("</filelists>\n")
+	return b.String()
+}
+
+func TestFileLists(t *testing.T) {
+	bash := testPackage("bash", "4.2.46", "34.el7", 1)
+	zlib := testPackage("zlib", "1.2.7", "20.el7_9", 2)
+	r := &testRepodata{}
+	r.addData(t, "primary", testPrimary(bash, zlib))
+	r.addData(t, "filelists", testFilelists([]Package{bash, zlib}, [][]string{
+		{"/usr/bin/bash", "/usr/bin/sh"},
+		{"/usr/lib64/libz.so.1"},
+	}))
+	srv := r.serve(t)
+
+	ctx := context.Background()
+	repo, err := LoadRepo(ctx, &Source{Mirrors: []string{srv.URL}, Fields: FieldNEVRA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !repo.HasFileLists() {
+		t.Fatal("no filelists found")
+	}
+	if repo.Files != nil {
+		t.Errorf("filelists read in by LoadRepo")
+	}
+
+	// Only the file lists asked for are kept
+	if err = repo.LoadFileLists(ctx, map[string]bool{zlib.Checksum.Text: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Files) != 1 {
+		t.Errorf("got %d file lists, want 1", len(repo.Files))
+	}
+	if f, ok := repo.FileList(zlib); !ok || len(f.Files) != 1 || f.Files[0].Path != "/usr/lib64/libz.so.1" {
+		t.Errorf("got %+v for zlib", f)
+	}
+	if _, ok := repo.FileList(bash); ok {
+		t.Errorf("bash file list was kept")
+	}
+
+	p, err := ParsePattern("/usr/bin/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := repo.WhatProvides(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].NEVRA() != bash.NEVRA() {
+		t.Errorf("got %+v, want %s", found, bash.NEVRA())
+	}
+}
+
+func TestFileDiff(t *testing.T) {
+	oldFiles := FileList{Files: []File{{Path: "/usr/bin/a"}, {Path: "/usr/bin/foo"}}}
+	newFiles := FileList{Files: []File{{Path: "/usr/bin/a2"}, {Path: "/usr/bin/a"}}}
+	added, removed := FileDiff(oldFiles, newFiles)
+	if !reflect.DeepEqual(added, []string{"/usr/bin/a2"}) || !reflect.DeepEqual(removed, []string{"/usr/bin/foo"}) {
+		t.Errorf("got added %q, removed %q", added, removed)
+	}
+}
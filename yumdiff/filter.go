@@ -0,0 +1,106 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pattern matches a package name or href, either with a shell glob or, when
+// prefixed with re:, a regular expression
+type Pattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// ParsePattern compiles a glob or re: regular expression
+func ParsePattern(s string) (Pattern, error) {
+	if expr, ok := strings.CutPrefix(s, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return Pattern{}, fmt.Errorf("Invalid pattern %q: %w", s, err)
+		}
+		return Pattern{re: re}, nil
+	}
+	if _, err := path.Match(s, ""); err != nil {
+		return Pattern{}, fmt.Errorf("Invalid pattern %q: %w", s, err)
+	}
+	return Pattern{glob: s}, nil
+}
+
+// Match reports if the value matches the pattern, a glob must match the whole
+// value and a regular expression any part of it
+func (p Pattern) Match(value string) bool {
+	if p.re != nil {
+		return p.re.MatchString(value)
+	}
+	ok, _ := path.Match(p.glob, value)
+	return ok
+}
+
+// Filter selects the entries to keep, the patterns are tried on both the name
+// and href of the entry.  The entries need to be loaded with FieldNEVRA for
+// the names and arches to be known.
+type Filter struct {
+	// Include keeps only the entries matching one of the patterns, when any
+	Include []Pattern
+	// Exclude drops the entries matching any of the patterns
+	Exclude []Pattern
+	// Arch keeps only the entries of one of the arches, when any
+	Arch []string
+}
+
+// Keep reports if the entry passes the filter
+func (f *Filter) Keep(m Matchable) bool {
+	e := m.Entry()
+	if len(f.Arch) > 0 {
+		found := false
+		for _, arch := range f.Arch {
+			if e.Arch == arch {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	matchAny := func(patterns []Pattern) bool {
+		for _, p := range patterns {
+			if p.Match(e.Name) || p.Match(e.Href) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(f.Include) > 0 && !matchAny(f.Include) {
+		return false
+	}
+	return !matchAny(f.Exclude)
+}
+
+// FilterEntries returns the entries which pass the filter, in the same order
+func FilterEntries(pkgs []Matchable, f *Filter) []Matchable {
+	ret := []Matchable{}
+	for _, m := range pkgs {
+		if f.Keep(m) {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,82 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+const armorBegin = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+
+// LoadKeyring reads in the armored public keys from a list of local files, file://
+// or HTTP(S) URLs, each file may hold more than one armored key block.
+func LoadKeyring(ctx context.Context, fileNames []string) (openpgp.EntityList, error) {
+	var keyring openpgp.EntityList
+	for _, fileName := range fileNames {
+		contents, err := readAll(ctx, strings.TrimPrefix(fileName, "file://"))
+		if err != nil {
+			return nil, err
+		}
+		blocks := strings.Split(string(contents), armorBegin)
+		for _, block := range blocks[1:] {
+			keys, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armorBegin + block))
+			if err != nil {
+				return nil, fmt.Errorf("Error reading keys from %s: %v", fileName, err)
+			}
+			keyring = append(keyring, keys...)
+		}
+		Logger.Println("Loaded", len(keyring), "keys from", fileName)
+	}
+	if len(keyring) == 0 {
+		return nil, errors.New("No public keys found in keyring")
+	}
+	return keyring, nil
+}
+
+// readAscFile reads in the detached signature for the repomd.xml file, either
+// from a local file or over HTTP.
+func (r *Repomd) readAscFile(ctx context.Context, ascFile string) error {
+	Logger.Println("Reading in file", ascFile)
+	contents, err := readAll(ctx, ascFile)
+	if err != nil {
+		return fmt.Errorf("%w, could not read signature file %s: %w", ErrSignature, ascFile, err)
+	}
+	r.ascFileContents = string(contents)
+	return nil
+}
+
+// verifySignature checks the detached signature of the repomd.xml contents
+// against the keyring.
+func (r *Repomd) verifySignature(keyring openpgp.EntityList) error {
+	if r.ascFileContents == "" {
+		return fmt.Errorf("%w, no signature loaded for %s", ErrSignature, r.path)
+	}
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring,
+		bytes.NewReader(r.fileContents), strings.NewReader(r.ascFileContents), nil)
+	if err != nil {
+		return fmt.Errorf("%w for %s: %v", ErrSignature, r.path, err)
+	}
+	for _, id := range signer.Identities {
+		Logger.Println("Good signature on", r.path, "from", id.Name)
+		break
+	}
+	return nil
+}
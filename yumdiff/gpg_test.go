@@ -0,0 +1,104 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// testSigner generates a key and returns it along with its armored public key
+func testSigner(t *testing.T) (*openpgp.Entity, []byte) {
+	entity, err := openpgp.NewEntity("Test Repo", "", "repo@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var key bytes.Buffer
+	w, err := armor.Encode(&key, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return entity, key.Bytes()
+}
+
+// sign adds a repomd.xml.asc signed by the entity to the repository tree
+func (r *testRepodata) sign(t *testing.T, entity *openpgp.Entity) {
+	var asc bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&asc, entity, bytes.NewReader(r.files["repodata/repomd.xml"]), nil); err != nil {
+		t.Fatal(err)
+	}
+	r.files["repodata/repomd.xml.asc"] = asc.Bytes()
+}
+
+func TestVerifySignature(t *testing.T) {
+	entity, key := testSigner(t)
+	other, _ := testSigner(t)
+
+	keySrv := (&testRepodata{files: map[string][]byte{"RPM-GPG-KEY-test": key}}).serve(t)
+	keyring, err := LoadKeyring(context.Background(), []string{keySrv.URL + "/RPM-GPG-KEY-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good := newTestRepodata(t)
+	good.sign(t, entity)
+
+	tampered := newTestRepodata(t)
+	tampered.sign(t, entity)
+	tampered.files["repodata/repomd.xml"] = append(tampered.files["repodata/repomd.xml"], "<!-- -->\n"...)
+
+	wrongKey := newTestRepodata(t)
+	wrongKey.sign(t, other)
+
+	unsigned := newTestRepodata(t)
+
+	tests := []struct {
+		name     string
+		repo     *testRepodata
+		optional bool
+		want     error
+	}{
+		{"good", good, false, nil},
+		{"tampered", tampered, false, ErrSignature},
+		{"tampered optional", tampered, true, ErrSignature},
+		{"wrong key", wrongKey, false, ErrSignature},
+		{"missing asc", unsigned, false, ErrSignature},
+		{"missing asc optional", unsigned, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := tt.repo.serve(t)
+			src := &Source{Mirrors: []string{srv.URL}, Keyring: keyring, SignatureOptional: tt.optional}
+			repo, err := LoadRepo(context.Background(), src)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if err == nil && len(repo.Packages) != 2 {
+				t.Errorf("got %d packages, want 2", len(repo.Packages))
+			}
+		})
+	}
+}
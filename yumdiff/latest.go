@@ -0,0 +1,61 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import "sort"
+
+// newerPackage reports if p1 is a newer build than p2, the build time is used
+// to break the tie when both have the same EVR
+func newerPackage(p1, p2 Package) bool {
+	if c := CompareEVR(p1.Version, p2.Version); c != 0 {
+		return c > 0
+	}
+	return p1.Time.Build > p2.Time.Build
+}
+
+// KeepLatest keeps only the n newest builds of every package name and arch,
+// any entries which are not packages (such as deltas) are passed through and
+// the original order of the list is kept.
+func KeepLatest(pkgs []Matchable, n int) []Matchable {
+	var byName = make(map[string][]int)
+	for i, m := range pkgs {
+		if p, ok := m.(Package); ok {
+			id := p.Name + "." + p.Arch
+			byName[id] = append(byName[id], i)
+		}
+	}
+
+	var keep = make([]bool, len(pkgs))
+	for _, idx := range byName {
+		sort.SliceStable(idx, func(i, j int) bool {
+			return newerPackage(pkgs[idx[i]].(Package), pkgs[idx[j]].(Package))
+		})
+		if len(idx) > n {
+			idx = idx[:n]
+		}
+		for _, i := range idx {
+			keep[i] = true
+		}
+	}
+
+	ret := []Matchable{}
+	for i, m := range pkgs {
+		if _, ok := m.(Package); ok && !keep[i] {
+			continue
+		}
+		ret = append(ret, m)
+	}
+	return ret
+}
@@ -0,0 +1,149 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Repo is the loaded contents of a repository
+type Repo struct {
+	// Mirror is the location the repository was loaded from
+	Mirror string
+	// Repomd is nil when a Package.xml file was loaded on its own
+	Repomd   *Repomd
+	Packages []Matchable
+	// Files are the file lists keyed by pkgid, only those asked for with
+	// LoadFileLists
+	Files map[string]FileList
+	// Other are the changelogs keyed by pkgid, only those asked for with
+	// LoadOther
+	Other map[string]OtherData
+	// Updates are the advisories, only loaded with FieldAdvisories
+	Updates     []Update
+	updateIndex map[string][]int
+	// Comps are the package groups, only loaded with FieldGroups
+	Comps *Comps
+}
+
+// LoadRepo loads the package lists from the first mirror of the source which
+// works, the error from every mirror tried is returned when none do.
+func LoadRepo(ctx context.Context, src *Source) (*Repo, error) {
+	if len(src.Mirrors) == 0 {
+		return nil, errors.New("No mirrors to load the repository from")
+	}
+
+	var errs mirrorsError
+	for _, mirror := range src.Mirrors {
+		repo, err := loadMirror(ctx, mirror, src)
+		if err == nil {
+			return repo, nil
+		}
+		if len(src.Mirrors) == 1 {
+			return nil, err
+		}
+		Logger.Println("Error loading from mirror", mirror, err)
+		errs = append(errs, err)
+	}
+	return nil, errs
+}
+
+// loadMirror reads in a Package.xml file or all the package lists referenced
+// from a repodata/ dir or repository URL, when a keyring is loaded the
+// repomd.xml signature must verify before anything is read from the dir.
+func loadMirror(ctx context.Context, fileName string, src *Source) (repo *Repo, err error) {
+	repo = &Repo{Mirror: fileName}
+
+	// The base the location hrefs are relative to, only used for URLs as a
+	// local repodata/ dir may have been copied on its own
+	var baseURL string
+	if IsURL(fileName) {
+		if isMetadataFile(fileName) {
+			repo.Packages, err = readSingleFile(ctx, fileName, src)
+			return repo, err
+		}
+		baseURL = strings.TrimSuffix(strings.TrimSuffix(fileName, "/"), "/repodata")
+		fileName = joinURL(baseURL, "repodata")
+	} else if _, isdir := IsDirectory(fileName); !isdir {
+		repo.Packages, err = readSingleFile(ctx, fileName, src)
+		return repo, err
+	}
+
+	repomdFile := JoinPath(fileName, "repomd.xml")
+	repomd, err := readRepomdFile(ctx, repomdFile)
+	if err != nil {
+		return nil, err
+	}
+	repomd.mirror = baseURL
+	repo.Repomd = repomd
+	if err = src.verifyHashes(repomd); err != nil {
+		return nil, err
+	}
+	if src.Keyring != nil {
+		if err = repomd.readAscFile(ctx, repomdFile+".asc"); err != nil {
+			if !src.SignatureOptional {
+				return nil, err
+			}
+			Logger.Println("Skipping signature check", err)
+		} else if err = repomd.verifySignature(src.Keyring); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, d := range repomd.Data {
+		dataFile := repomd.dataFile(d)
+		var p []Matchable
+		switch d.Type {
+		case "primary":
+			if p, err = readFile(ctx, dataFile, &repomd.Data[i], src.Fields); err != nil {
+				return nil, err
+			}
+		case "prestodelta":
+			if p, err = readDeltaFile(ctx, dataFile, &repomd.Data[i]); err != nil {
+				return nil, err
+			}
+		case "updateinfo":
+			if src.Fields&FieldAdvisories == 0 {
+				continue
+			}
+			if repo.Updates, err = readUpdateinfo(ctx, dataFile, &repomd.Data[i]); err != nil {
+				return nil, err
+			}
+			repo.updateIndex = indexUpdates(repo.Updates)
+		case "group", "group_gz":
+			// Both are the same comps, only one is read
+			if src.Fields&FieldGroups == 0 || repo.Comps != nil {
+				continue
+			}
+			if repo.Comps, err = readComps(ctx, dataFile, &repomd.Data[i]); err != nil {
+				return nil, err
+			}
+		}
+		repo.Packages = append(repo.Packages, p...)
+	}
+	return repo, nil
+}
+
+// readSingleFile reads in a Package.xml file given on its own, these cannot be
+// verified as there is no repomd.xml
+func readSingleFile(ctx context.Context, fileName string, src *Source) ([]Matchable, error) {
+	if src.Keyring != nil {
+		return nil, fmt.Errorf("%w, a repodata/ dir is needed to verify signatures, got file: %s", ErrSignature, fileName)
+	}
+	return readFile(ctx, fileName, nil, src.Fields)
+}
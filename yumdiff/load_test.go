@@ -0,0 +1,289 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testRepodata is a generated repository tree, keyed by the path relative to
+// the repository base
+type testRepodata struct {
+	files map[string][]byte
+	data  []string
+}
+
+// addData gzips the metadata and lists it in the repomd.xml under the type
+func (r *testRepodata) addData(t testing.TB, dataType, contents string) {
+	if r.files == nil {
+		r.files = make(map[string][]byte)
+	}
+	compressed := gzipBytes(t, []byte(contents))
+	href := "repodata/" + sha256Sum(compressed) + "-" + dataType + ".xml.gz"
+	r.files[href] = compressed
+	r.data = append(r.data, fmt.Sprintf(`  <data type="%s">
+    <checksum type="sha256">%s</checksum>
+    <open-checksum type="sha256">%s</open-checksum>
+    <location href="%s"/>
+    <timestamp>1647000000</timestamp>
+    <size>%d</size>
+    <open-size>%d</open-size>
+  </data>
+`, dataType, sha256Sum(compressed), sha256Sum([]byte(contents)), href, len(compressed), len(contents)))
+	r.files["repodata/repomd.xml"] = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo" xmlns:rpm="http://linux.duke.edu/metadata/rpm">
+  <revision>1647000000</revision>
+` + strings.Join(r.data, "") + "</repomd>\n")
+}
+
+// href returns the location of the metadata of the type
+func (r *testRepodata) href(dataType string) string {
+	for f := range r.files {
+		if strings.HasSuffix(f, "-"+dataType+".xml.gz") {
+			return f
+		}
+	}
+	return ""
+}
+
+// testPrimary builds the primary.xml of the packages
+func testPrimary(pkgs ...Package) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="%d">
+`, len(pkgs))
+	for _, p := range pkgs {
+		fmt.Fprintf(&b, `<package type="rpm">
+  <name>%s</name>
+  <arch>%s</arch>
+  <version epoch="%s" ver="%s" rel="%s"/>
+  <checksum type="%s" pkgid="YES">%s</checksum>
+  <time file="%d" build="%d"/>
+  <size package="%s" installed="0" archive="0"/>
+  <location href="%s"/>
+</package>
+`, p.Name, p.Arch, p.Version.Epoch, p.Version.Ver, p.Version.Rel, p.Checksum.Type, p.Checksum.Text,
+			p.Time.File, p.Time.Build, p.Size.Package, p.Location.Href)
+	}
+	b.WriteString("</metadata>\n")
+	return b.String()
+}
+
+// serve starts an HTTP server for the repository tree, anything not in the
+// tree is a 404
+func (r *testRepodata) serve(t testing.TB) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		contents, ok := r.files[strings.TrimPrefix(req.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write(contents)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestRepodata(t testing.TB) *testRepodata {
+	r := &testRepodata{}
+	r.addData(t, "primary", testPrimary(
+		testPackage("bash", "4.2.46", "34.el7", 1),
+		testPackage("zlib", "1.2.7", "20.el7_9", 2),
+	))
+	return r
+}
+
+func TestLoadRepoHTTP(t *testing.T) {
+	srv := newTestRepodata(t).serve(t)
+
+	repo, err := LoadRepo(context.Background(), &Source{Mirrors: []string{srv.URL + "/"}, Fields: FieldNEVRA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Repomd == nil {
+		t.Fatal("repomd.xml was not loaded")
+	}
+	var got []string
+	for _, m := range repo.Packages {
+		got = append(got, m.Entry().NEVRA)
+	}
+	want := "bash-4.2.46-34.el7.x86_64 zlib-1.2.7-20.el7_9.x86_64"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got packages %q, want %q", got, want)
+	}
+}
+
+func TestLoadRepoHTTPErrors(t *testing.T) {
+	corrupt := newTestRepodata(t)
+	href := corrupt.href("primary")
+	b := append([]byte(nil), corrupt.files[href]...)
+	b[len(b)/2] ^= 0xFF
+	corrupt.files[href] = b
+
+	missing := newTestRepodata(t)
+	delete(missing.files, "repodata/repomd.xml")
+
+	tests := []struct {
+		name string
+		repo *testRepodata
+		want error
+	}{
+		{"corrupt primary", corrupt, ErrChecksumMismatch},
+		{"missing repomd", missing, ErrRepomdMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := tt.repo.serve(t)
+			_, err := LoadRepo(context.Background(), &Source{Mirrors: []string{srv.URL}})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRepoMirrorlist(t *testing.T) {
+	good := newTestRepodata(t)
+	goodSrv := good.serve(t)
+	broken := newTestRepodata(t)
+	delete(broken.files, broken.href("primary"))
+	brokenSrv := broken.serve(t)
+	missingSrv := (&testRepodata{}).serve(t)
+
+	lists := (&testRepodata{files: map[string][]byte{
+		"working": []byte("# first the broken mirrors\n" + missingSrv.URL + "/\n" + brokenSrv.URL + "/\n\n" + goodSrv.URL + "/\n"),
+		"broken":  []byte(missingSrv.URL + "/\n" + brokenSrv.URL + "/\n"),
+	}}).serve(t)
+
+	src, err := ResolveSource(context.Background(), "mirrorlist="+lists.URL+"/working")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(src.Mirrors) != 3 {
+		t.Fatalf("got mirrors %q, want 3", src.Mirrors)
+	}
+	repo, err := LoadRepo(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Mirror != goodSrv.URL+"/" || len(repo.Packages) != 2 {
+		t.Errorf("loaded %d packages from %s, want 2 from %s/", len(repo.Packages), repo.Mirror, goodSrv.URL)
+	}
+
+	// Every mirror fails, the cause from each is kept
+	src, err = ResolveSource(context.Background(), "mirrorlist="+lists.URL+"/broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadRepo(context.Background(), src)
+	if !errors.Is(err, ErrRepomdMissing) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v, want both %v and %v", err, ErrRepomdMissing, fs.ErrNotExist)
+	}
+}
+
+// testMetalink builds a metalink for the repomd.xml, with a mirror for each
+// base URL at the given preference
+func testMetalink(hashes, altHashes []string, urls []string, prefs []int) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/" xmlns:mm0="http://fedorahosted.org/mirrormanager">
+ <files>
+  <file name="repomd.xml">
+   <size>1000</size>
+   <verification>
+    <hash type="md5">d41d8cd98f00b204e9800998ecf8427e</hash>
+`)
+	for _, h := range hashes {
+		fmt.Fprintf(&b, "    <hash type=\"sha256\">%s</hash>\n", h)
+	}
+	b.WriteString("   </verification>\n   <mm0:alternates>\n")
+	for _, h := range altHashes {
+		fmt.Fprintf(&b, "    <mm0:alternate>\n     <verification>\n      <hash type=\"sha256\">%s</hash>\n     </verification>\n    </mm0:alternate>\n", h)
+	}
+	b.WriteString("   </mm0:alternates>\n   <resources maxconnections=\"1\">\n")
+	for i, u := range urls {
+		fmt.Fprintf(&b, "    <url protocol=\"http\" type=\"http\" location=\"US\" preference=\"%d\">%s/repodata/repomd.xml</url>\n", prefs[i], u)
+	}
+	b.WriteString("   </resources>\n  </file>\n </files>\n</metalink>\n")
+	return []byte(b.String())
+}
+
+func TestLoadRepoMetalink(t *testing.T) {
+	good := newTestRepodata(t)
+	goodSrv := good.serve(t)
+	brokenSrv := (&testRepodata{}).serve(t)
+	repomdSum := sha256Sum(good.files["repodata/repomd.xml"])
+	otherSum := sha256Sum([]byte("an older repomd.xml"))
+
+	lists := (&testRepodata{files: map[string][]byte{
+		// The broken mirror is preferred, so it is tried first
+		"metalink": testMetalink([]string{repomdSum}, nil,
+			[]string{goodSrv.URL, brokenSrv.URL}, []int{90, 100}),
+		// The repomd.xml only matches the hash of an alternate
+		"alternate": testMetalink([]string{otherSum}, []string{repomdSum},
+			[]string{goodSrv.URL}, []int{100}),
+		"mismatch": testMetalink([]string{otherSum}, []string{otherSum},
+			[]string{goodSrv.URL}, []int{100}),
+		"empty":     testMetalink([]string{repomdSum}, nil, nil, nil),
+		"malformed": []byte("<metalink><files>"),
+	}}).serve(t)
+
+	ctx := context.Background()
+	src, err := ResolveSource(ctx, "metalink="+lists.URL+"/metalink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{brokenSrv.URL + "/", goodSrv.URL + "/"}; strings.Join(src.Mirrors, " ") != strings.Join(want, " ") {
+		t.Errorf("got mirrors %q, want %q", src.Mirrors, want)
+	}
+
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"metalink", nil},
+		{"alternate", nil},
+		{"mismatch", ErrChecksumMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := ResolveSource(ctx, "metalink="+lists.URL+"/"+tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo, err := LoadRepo(ctx, src)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if err == nil && (repo.Mirror != goodSrv.URL+"/" || len(repo.Packages) != 2) {
+				t.Errorf("loaded %d packages from %s, want 2 from %s/", len(repo.Packages), repo.Mirror, goodSrv.URL)
+			}
+		})
+	}
+
+	if _, err = ResolveSource(ctx, "metalink="+lists.URL+"/empty"); err == nil || !strings.Contains(err.Error(), "No repomd.xml mirrors") {
+		t.Errorf("got %v for a metalink without mirrors", err)
+	}
+	if _, err = ResolveSource(ctx, "metalink="+lists.URL+"/malformed"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("got %v, want %v", err, ErrMalformed)
+	}
+}
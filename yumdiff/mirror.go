@@ -0,0 +1,178 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// Metalink is the Fedora mirrormanager style metalink (version 3.0) document,
+// only the repomd.xml file entry is of interest
+type Metalink struct {
+	XMLName xml.Name `xml:"metalink"`
+	Files   []struct {
+		Name         string         `xml:"name,attr"`
+		Size         int64          `xml:"size"`
+		Verification []MetalinkHash `xml:"verification>hash"`
+		Alternates   []struct {
+			Size         int64          `xml:"size"`
+			Verification []MetalinkHash `xml:"verification>hash"`
+		} `xml:"alternates>alternate"`
+		Resources []struct {
+			Text       string `xml:",chardata"`
+			Protocol   string `xml:"protocol,attr"`
+			Preference int    `xml:"preference,attr"`
+		} `xml:"resources>url"`
+	} `xml:"files>file"`
+}
+
+type MetalinkHash struct {
+	Text string `xml:",chardata"`
+	Type string `xml:"type,attr"`
+}
+
+// Source is a repository location resolved into the list of mirrors to try in
+// order, along with the hash sets the repomd.xml must match and the keyring
+// its signature must verify against when given
+type Source struct {
+	Mirrors []string
+	Hashes  [][]MetalinkHash
+	Keyring openpgp.EntityList
+
+	// Fields are the optional package fields to decode
+	Fields Fields
+
+	// SignatureOptional only verifies the signature when a repomd.xml.asc exists
+	SignatureOptional bool
+}
+
+// ResolveSource turns a source location into the mirrors to try, a value of
+// mirrorlist=URL or metalink=URL is fetched and expanded, anything else (a
+// Package.xml file, repodata/ dir or repository URL) is used as is.
+func ResolveSource(ctx context.Context, source string) (*Source, error) {
+	switch {
+	case strings.HasPrefix(source, "mirrorlist="):
+		return readMirrorlist(ctx, strings.TrimPrefix(source, "mirrorlist="))
+	case strings.HasPrefix(source, "metalink="):
+		return readMetalink(ctx, strings.TrimPrefix(source, "metalink="))
+	}
+	return &Source{Mirrors: []string{source}}, nil
+}
+
+// readMirrorlist reads in a plain text list of base URLs, one per line
+func readMirrorlist(ctx context.Context, mirrorlist string) (*Source, error) {
+	Logger.Println("Reading in mirrorlist", mirrorlist)
+	contents, err := readAll(ctx, mirrorlist)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &Source{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if IsURL(line) {
+			src.Mirrors = append(src.Mirrors, line)
+		}
+	}
+	if len(src.Mirrors) == 0 {
+		return nil, fmt.Errorf("No mirrors found in mirrorlist %s", mirrorlist)
+	}
+	return src, nil
+}
+
+// readMetalink reads in a metalink document, the mirrors are sorted by their
+// preference and the repomd.xml hashes are kept for verification
+func readMetalink(ctx context.Context, metalink string) (*Source, error) {
+	Logger.Println("Reading in metalink", metalink)
+	contents, err := readAll(ctx, metalink)
+	if err != nil {
+		return nil, err
+	}
+
+	var dat Metalink
+	if err = xml.Unmarshal(contents, &dat); err != nil {
+		return nil, fmt.Errorf("%w, error decoding metalink %s: %w", ErrMalformed, metalink, err)
+	}
+
+	src := &Source{}
+	for _, f := range dat.Files {
+		if f.Name != "repomd.xml" {
+			continue
+		}
+		if len(f.Verification) > 0 {
+			src.Hashes = append(src.Hashes, f.Verification)
+		}
+		// Mirrors which are behind may still serve a recent repomd.xml
+		for _, alt := range f.Alternates {
+			if len(alt.Verification) > 0 {
+				src.Hashes = append(src.Hashes, alt.Verification)
+			}
+		}
+		sort.SliceStable(f.Resources, func(i, j int) bool {
+			return f.Resources[i].Preference > f.Resources[j].Preference
+		})
+		for _, u := range f.Resources {
+			u.Text = strings.TrimSpace(u.Text)
+			if !IsURL(u.Text) || !strings.HasSuffix(u.Text, "repodata/repomd.xml") {
+				continue
+			}
+			src.Mirrors = append(src.Mirrors, strings.TrimSuffix(u.Text, "repodata/repomd.xml"))
+		}
+	}
+	if len(src.Mirrors) == 0 {
+		return nil, fmt.Errorf("No repomd.xml mirrors found in metalink %s", metalink)
+	}
+	return src, nil
+}
+
+// verifyHashes checks the repomd.xml contents against the metalink hashes, the
+// file needs to match every supported hash of at least one of the hash sets
+func (src *Source) verifyHashes(r *Repomd) error {
+	if len(src.Hashes) == 0 {
+		return nil
+	}
+	for _, set := range src.Hashes {
+		var matched, failed bool
+		for _, mh := range set {
+			h, err := newHash(mh.Type)
+			if err != nil {
+				// Skip the types we don't support, like md5
+				continue
+			}
+			h.Write(r.fileContents)
+			if fmt.Sprintf("%x", h.Sum(nil)) == strings.TrimSpace(mh.Text) {
+				matched = true
+			} else {
+				failed = true
+			}
+		}
+		if matched && !failed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w for %s against the metalink hashes", ErrChecksumMismatch, r.path)
+}
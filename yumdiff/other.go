@@ -0,0 +1,98 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// OtherData is a <package> entry of the other.xml file, the pkgid is the
+// checksum of the package in the primary.xml file
+type OtherData struct {
+	PkgID      string      `xml:"pkgid,attr"`
+	Name       string      `xml:"name,attr"`
+	Arch       string      `xml:"arch,attr"`
+	Version    EVR         `xml:"version"`
+	Changelogs []Changelog `xml:"changelog"`
+}
+
+// Changelog is a changelog entry of a package, the date is in unix seconds
+type Changelog struct {
+	Author string `xml:"author,attr"`
+	Date   int64  `xml:"date,attr"`
+	Text   string `xml:",chardata"`
+}
+
+// Time gives the date of the changelog entry
+func (c Changelog) Time() time.Time { return time.Unix(c.Date, 0).UTC() }
+
+// StreamOther reads in the other file and calls fn with each package as it is
+// decoded, when the repomd data entry is given the checksums are verified.
+func StreamOther(ctx context.Context, fileName string, d *RepomdData, fn func(OtherData) error) error {
+	return streamPackageElements(ctx, fileName, d, "otherdata", fn)
+}
+
+// HasOther reports if the repository has an other file to read the changelogs
+// from, only a repodata/ dir can have one
+func (r *Repo) HasOther() bool {
+	return r != nil && r.Repomd.findData("other") != nil
+}
+
+// LoadOther reads in the changelogs of the packages with the pkgids into
+// Repo.Other, the others are skipped over as the other file of a whole
+// repository is large
+func (r *Repo) LoadOther(ctx context.Context, pkgids map[string]bool) error {
+	d := r.Repomd.findData("other")
+	if d == nil {
+		return fmt.Errorf("No other data in %s", r.Mirror)
+	}
+	r.Other = make(map[string]OtherData)
+	return StreamOther(ctx, r.Repomd.dataFile(*d), d, func(o OtherData) error {
+		if pkgids[o.PkgID] {
+			r.Other[o.PkgID] = o
+		}
+		return nil
+	})
+}
+
+// OtherData returns the changelog of the package, which is only known once it
+// was read in with LoadOther
+func (r *Repo) OtherData(m Matchable) (OtherData, bool) {
+	p, ok := m.(Package)
+	if !ok || r == nil {
+		return OtherData{}, false
+	}
+	o, ok := r.Other[p.Checksum.Text]
+	return o, ok
+}
+
+// NewChangelogs returns the changelog entries of the new build which are dated
+// after the newest entry of the old build, in the order of the new build
+func NewChangelogs(oldData, newData OtherData) (entries []Changelog) {
+	var newest int64
+	for _, c := range oldData.Changelogs {
+		if c.Date > newest {
+			newest = c.Date
+		}
+	}
+	for _, c := range newData.Changelogs {
+		if c.Date > newest {
+			entries = append(entries, c)
+		}
+	}
+	return
+}
@@ -0,0 +1,91 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testOther builds the other.xml of the packages, each with the changelog
+// entries given for it as date and text
+func testOther(pkgs []Package, changelogs [][]Changelog) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<otherdata xmlns="http://linux.duke.edu/metadata/other" packages="%d">
+`, len(pkgs))
+	for i, p := range pkgs {
+		fmt.Fprintf(&b, `<package pkgid="%s" name="%s" arch="%s">
+  <version epoch="%s" ver="%s" rel="%s"/>
+`, p.Checksum.Text, p.Name, p.Arch, p.Version.Epoch, p.Version.Ver, p.Version.Rel)
+		for _, c := range changelogs[i] {
+			fmt.Fprintf(&b, "  <changelog author=\"%s\" date=\"%d\">%s</changelog>\n", c.Author, c.Date, c.Text)
+		}
+		b.WriteString("</package>\n")
+	}
+	b.WriteString("</otherdata>\n")
+	return b.String()
+}
+
+func TestLoadOther(t *testing.T) {
+	a1 := testPackage("A", "1", "1", 1)
+	a2 := testPackage("A", "2", "1", 2)
+	r := &testRepodata{}
+	r.addData(t, "primary", testPrimary(a1, a2))
+	r.addData(t, "other", testOther([]Package{a1, a2}, [][]Changelog{
+		{{Author: "Dev - 1-1", Date: 100, Text: "- First"}},
+		{{Author: "Dev - 2-1", Date: 300, Text: "- Second"}, {Author: "Dev - 1.5-1", Date: 200, Text: "- Middle"},
+			{Author: "Dev - 1-1", Date: 100, Text: "- First"}},
+	}))
+	srv := r.serve(t)
+
+	ctx := context.Background()
+	repo, err := LoadRepo(ctx, &Source{Mirrors: []string{srv.URL}, Fields: FieldNEVRA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !repo.HasOther() {
+		t.Fatal("no other data found")
+	}
+	if repo.Other != nil {
+		t.Errorf("other data read in by LoadRepo")
+	}
+
+	// Only the changelogs asked for are kept
+	if err = repo.LoadOther(ctx, map[string]bool{a2.Checksum.Text: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Other) != 1 {
+		t.Errorf("got %d changelogs, want 1", len(repo.Other))
+	}
+	if _, ok := repo.OtherData(a1); ok {
+		t.Errorf("A-1 changelog was kept")
+	}
+	newData, ok := repo.OtherData(a2)
+	if !ok {
+		t.Fatal("no changelog for A-2")
+	}
+
+	oldData := OtherData{Changelogs: []Changelog{{Date: 100}}}
+	var got []string
+	for _, c := range NewChangelogs(oldData, newData) {
+		got = append(got, c.Text)
+	}
+	if strings.Join(got, " ") != "- Second - Middle" {
+		t.Errorf("got new changelogs %q", got)
+	}
+}
@@ -0,0 +1,459 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Matchable is an entry of a repository file list, either a Package or a
+// DeltaPackage
+type Matchable interface {
+	// Key returns the identity of the entry, two entries with the same key are
+	// considered to be the same file in both the new and old lists
+	Key() string
+	// FileSize is the size in bytes of the file to download
+	FileSize() uint64
+	// Entry gives the fields of the entry in a common form
+	Entry() Entry
+}
+
+// Entry is the common form of a Package or DeltaPackage, for output formats
+// which need more than the file list line
+type Entry struct {
+	// Kind is either "package" or "delta"
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Arch    string `json:"arch"`
+	Epoch   string `json:"epoch"`
+	Version string `json:"version"`
+	Release string `json:"release"`
+	// NEVRA is the name-[epoch:]version-release.arch, for a delta this is the
+	// package the delta builds
+	NEVRA        string `json:"nevra"`
+	ChecksumType string `json:"checksumType"`
+	Checksum     string `json:"checksum"`
+	Size         uint64 `json:"size"`
+	// Href is the location of the file relative to the repository base
+	Href string `json:"href"`
+}
+
+// EVR returns the epoch, version and release of the entry
+func (e Entry) EVR() EVR { return EVR{Epoch: e.Epoch, Ver: e.Version, Rel: e.Release} }
+
+// open reads in a local file or URL, the compression type is detected and the
+// returned reader gives the decompressed contents
+func open(ctx context.Context, fileName string) (file io.Reader, closure func(), err error) {
+	Logger.Println("Reading in file", fileName)
+
+	rawFile, err := openRaw(ctx, fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, closure, err = decompress(rawFile, fileName, func() { rawFile.Close() })
+	if err != nil {
+		rawFile.Close()
+	}
+	return
+}
+
+// openFile opens the file, when the repomd data entry is given the checksums are
+// verified
+func openFile(ctx context.Context, fileName string, d *RepomdData) (io.Reader, func(), error) {
+	if d != nil {
+		return openData(ctx, fileName, d)
+	}
+	return open(ctx, fileName)
+}
+
+// streamFile opens the file and hands each start element to fn, which decodes
+// what it needs from the decoder.  The file is read to the end once the last
+// element is decoded so the open checksum gets verified, when the repomd data
+// entry is given the checksums are verified.
+func streamFile(ctx context.Context, fileName string, d *RepomdData, fn func(*xml.Decoder, xml.StartElement) error) error {
+	file, closure, err := openFile(ctx, fileName, d)
+	if err != nil {
+		return err
+	}
+	defer closure()
+
+	decoder := xml.NewDecoder(file)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return decodeError(fileName, err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			if err = fn(decoder, se); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Read to the end so the open checksum gets verified
+	_, err = io.Copy(io.Discard, file)
+	return err
+}
+
+// The magic numbers at the start of the compressed file formats
+var (
+	magicXz    = []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}
+	magicGzip  = []byte{0x1F, 0x8B}
+	magicZstd  = []byte{0x28, 0xB5, 0x2F, 0xFD}
+	magicBzip2 = []byte{'B', 'Z', 'h'}
+	magicZck   = []byte{0x00, 'Z', 'C', 'K', '1'}
+)
+
+// decompress detects the compression used on the raw file by the magic number,
+// a file with none is read as plain xml whatever the extension says, closeRaw
+// is called from the returned closure
+func decompress(rawFile io.Reader, fileName string, closeRaw func()) (file io.Reader, closure func(), err error) {
+	bufReader := bufio.NewReaderSize(rawFile, 100000)
+
+	// Detect magic number, a short read leaves the file to be parsed as is
+	magic, _ := bufReader.Peek(len(magicXz))
+
+	switch {
+	case bytes.HasPrefix(magic, magicXz):
+		Logger.Println("Using xz decoder")
+		xzfile, err := xz.NewReader(bufReader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w, error opening xz file %s: %w", ErrMalformed, fileName, err)
+		}
+		return xzfile, closeRaw, nil
+
+	case bytes.HasPrefix(magic, magicGzip):
+		Logger.Println("Using gz decoder")
+		gz, err := gzip.NewReader(bufReader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w, error opening gzip file %s: %w", ErrMalformed, fileName, err)
+		}
+
+		// Make sure gzip handle is closed at the end of the function
+		closure = func() {
+			gz.Close()
+			closeRaw()
+		}
+		return gz, closure, nil
+
+	case bytes.HasPrefix(magic, magicZstd):
+		Logger.Println("Using zstd decoder")
+		zst, err := zstd.NewReader(bufReader, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w, error opening zstd file %s: %w", ErrMalformed, fileName, err)
+		}
+		closure = func() {
+			zst.Close()
+			closeRaw()
+		}
+		return zst, closure, nil
+
+	case bytes.HasPrefix(magic, magicBzip2):
+		Logger.Println("Using bzip2 decoder")
+		return bzip2.NewReader(bufReader), closeRaw, nil
+
+	case bytes.HasPrefix(magic, magicZck):
+		return nil, nil, fmt.Errorf("%w, zchunk in %s", ErrUnsupportedCompression, fileName)
+	}
+
+	// Some mirrors serve the decompressed file under the original name
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".xz", ".gz", ".zst", ".bz2":
+		Logger.Println("No magic number for the extension, reading as plain xml", fileName)
+	}
+	return bufReader, closeRaw, nil
+}
+
+type Package struct {
+	XMLName xml.Name `xml:"package"`
+	//Text     string   `xml:",chardata"`
+	//Type string `xml:"type,attr"`
+	Name     string `xml:"name"`
+	Arch     string `xml:"arch"`
+	Version  EVR    `xml:"version"`
+	Checksum struct {
+		Text string `xml:",chardata"`
+		Type string `xml:"type,attr"`
+		//Pkgid string `xml:"pkgid,attr"`
+	} `xml:"checksum"`
+	Size struct {
+		//Text      string `xml:",chardata"`
+		Package string `xml:"package,attr"`
+		//Installed string `xml:"installed,attr"`
+		//Archive   string `xml:"archive,attr"`
+	} `xml:"size"`
+	Location struct {
+		//Text string `xml:",chardata"`
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+	Time struct {
+		File  int64 `xml:"file,attr"`
+		Build int64 `xml:"build,attr"`
+	} `xml:"time"`
+}
+
+// NEVRA returns the name-[epoch:]version-release.arch string of the package
+func (p Package) NEVRA() string {
+	return p.Name + "-" + p.Version.String() + "." + p.Arch
+}
+
+func (p Package) Key() string {
+	return strings.Join([]string{"pkg", p.Checksum.Text, p.Checksum.Type,
+		p.Size.Package, p.Location.Href}, "\x00")
+}
+func (p Package) FileSize() uint64 { return atoi(p.Size.Package) }
+func (p Package) Entry() Entry {
+	return Entry{Kind: "package", Name: p.Name, Arch: p.Arch,
+		Epoch: p.Version.Epoch, Version: p.Version.Ver, Release: p.Version.Rel, NEVRA: p.NEVRA(),
+		ChecksumType: p.Checksum.Type, Checksum: p.Checksum.Text, Size: p.FileSize(), Href: p.Location.Href}
+}
+
+// Fields selects the optional fields which are decoded for each package, the
+// checksum, size and location are always decoded
+type Fields uint8
+
+const (
+	// FieldNEVRA decodes the name, arch, version and time of the package
+	FieldNEVRA Fields = 1 << iota
+	// FieldAdvisories also loads the updateinfo of a repodata/ dir into
+	// Repo.Updates
+	FieldAdvisories
+	// FieldGroups also loads the comps of a repodata/ dir into Repo.Comps
+	FieldGroups
+)
+
+// readFile reads in the file, when the repomd data entry is given the checksums
+// are verified
+func readFile(ctx context.Context, fileName string, d *RepomdData, fields Fields) ([]Matchable, error) {
+	var m []Matchable
+	err := StreamPrimary(ctx, fileName, d, fields, func(p Package) error {
+		m = append(m, p)
+		return nil
+	})
+	return m, err
+}
+
+// StreamPrimary decodes the packages of a primary.xml file one at a time and
+// hands each to the callback, the fields not selected are skipped over.  When
+// the repomd data entry is given the checksums are verified.
+func StreamPrimary(ctx context.Context, fileName string, d *RepomdData, fields Fields, fn func(Package) error) error {
+	var count, expected int
+	var haveExpected bool
+	err := streamFile(ctx, fileName, d, func(decoder *xml.Decoder, se xml.StartElement) error {
+		switch se.Name.Local {
+		case "metadata":
+			if v := attr(se, "packages"); v != "" {
+				expected, haveExpected = int(atoi(v)), true
+			}
+		case "package":
+			p, err := decodePackage(decoder, se, fields)
+			if err != nil {
+				return decodeError(fileName, err)
+			}
+			count++
+			return fn(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return fmt.Errorf("%w in %s", ErrEmptyRepo, fileName)
+	}
+	if haveExpected && count != expected {
+		return fmt.Errorf("%w in %s", ErrPackageCountMismatch, fileName)
+	}
+	return nil
+}
+
+// decodePackage reads the child elements of a <package> element up to and
+// including its end element
+func decodePackage(decoder *xml.Decoder, start xml.StartElement, fields Fields) (p Package, err error) {
+	p.XMLName = start.Name
+	wantNEVRA := fields&FieldNEVRA != 0
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return p, err
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			return p, nil
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "checksum":
+				p.Checksum.Type = attr(t, "type")
+				p.Checksum.Text, err = readText(decoder)
+				if err != nil {
+					return p, err
+				}
+				continue
+			case t.Name.Local == "size":
+				p.Size.Package = attr(t, "package")
+			case t.Name.Local == "location":
+				p.Location.Href = attr(t, "href")
+			case wantNEVRA && t.Name.Local == "name":
+				if p.Name, err = readText(decoder); err != nil {
+					return p, err
+				}
+				continue
+			case wantNEVRA && t.Name.Local == "arch":
+				if p.Arch, err = readText(decoder); err != nil {
+					return p, err
+				}
+				continue
+			case wantNEVRA && t.Name.Local == "version":
+				p.Version = EVR{Epoch: attr(t, "epoch"), Ver: attr(t, "ver"), Rel: attr(t, "rel")}
+			case wantNEVRA && t.Name.Local == "time":
+				p.Time.File = int64(atoi(attr(t, "file")))
+				p.Time.Build = int64(atoi(attr(t, "build")))
+			}
+			// Skip over the rest of the element, such as the large <format> block
+			if err = decoder.Skip(); err != nil {
+				return p, err
+			}
+		}
+	}
+}
+
+// attr returns the value of an attribute of the element
+func attr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// readText reads the character data of the current element up to and including
+// its end element
+func readText(decoder *xml.Decoder) (string, error) {
+	var text []byte
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text = append(text, t...)
+		case xml.StartElement:
+			if err = decoder.Skip(); err != nil {
+				return "", err
+			}
+		case xml.EndElement:
+			return string(text), nil
+		}
+	}
+}
+
+type DeltaPackage struct {
+	XMLName xml.Name `xml:"newpackage"`
+	Text    string   `xml:",chardata"`
+	Name    string   `xml:"name,attr"`
+	Epoch   string   `xml:"epoch,attr"`
+	Version string   `xml:"version,attr"`
+	Release string   `xml:"release,attr"`
+	Arch    string   `xml:"arch,attr"`
+	Delta   struct {
+		Text       string `xml:",chardata"`
+		Oldepoch   string `xml:"oldepoch,attr"`
+		Oldversion string `xml:"oldversion,attr"`
+		Oldrelease string `xml:"oldrelease,attr"`
+		Filename   string `xml:"filename"`
+		Sequence   string `xml:"sequence"`
+		Size       string `xml:"size"`
+		Checksum   struct {
+			Text string `xml:",chardata"`
+			Type string `xml:"type,attr"`
+		} `xml:"checksum"`
+	} `xml:"delta"`
+}
+
+// NEVRA returns the name-[epoch:]version-release.arch string of the package
+// the delta builds
+func (p DeltaPackage) NEVRA() string {
+	return p.Name + "-" + EVR{Epoch: p.Epoch, Ver: p.Version, Rel: p.Release}.String() + "." + p.Arch
+}
+
+func (p DeltaPackage) Key() string {
+	return strings.Join([]string{"delta", p.Name, p.Version, p.Release,
+		p.Delta.Oldversion, p.Delta.Oldrelease, p.Delta.Size, p.Delta.Checksum.Text}, "\x00")
+}
+func (p DeltaPackage) FileSize() uint64 { return atoi(p.Delta.Size) }
+func (p DeltaPackage) Entry() Entry {
+	return Entry{Kind: "delta", Name: p.Name, Arch: p.Arch,
+		Epoch: p.Epoch, Version: p.Version, Release: p.Release, NEVRA: p.NEVRA(),
+		ChecksumType: p.Delta.Checksum.Type, Checksum: p.Delta.Checksum.Text, Size: p.FileSize(), Href: p.Delta.Filename}
+}
+
+// readDeltaFile reads in the file, when the repomd data entry is given the checksums
+// are verified, the <newpackage> elements are decoded one at a time
+func readDeltaFile(ctx context.Context, fileName string, d *RepomdData) ([]Matchable, error) {
+	var m []Matchable
+	err := streamFile(ctx, fileName, d, func(decoder *xml.Decoder, se xml.StartElement) error {
+		if se.Name.Local != "newpackage" {
+			return nil
+		}
+		var p DeltaPackage
+		if err := decoder.DecodeElement(&p, &se); err != nil {
+			return decodeError(fileName, err)
+		}
+		m = append(m, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrEmptyRepo, fileName)
+	}
+	return m, nil
+}
+
+func atoi(str string) uint64 {
+	i, _ := strconv.Atoi(str)
+	return uint64(i)
+}
+
+// decodeError wraps an error from the xml decoder, errors from the underlying
+// reader (such as a checksum mismatch or network error) are passed through
+func decodeError(fileName string, err error) error {
+	var syntax *xml.SyntaxError
+	if errors.As(err, &syntax) || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w, error decoding %s: %w", ErrMalformed, fileName, err)
+	}
+	return fmt.Errorf("Error decoding %s: %w", fileName, err)
+}
@@ -0,0 +1,92 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// testBzip2XML is testXML compressed with bzip2, as there is no writer in the
+// standard library
+const testBzip2XML = "\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\xa8\x94\xf0\x0d\x00\x00\x0f\x5f\x80\x00\x10\x50\x03\xe0\x57\x81\x00\x06\x00\x2e\xef\xdf\x40\x20\x00\x68\x42\x9e\x90\x34\xd3\x4d\x3d\x35\x0d\x34\xd0\xd1\xa0\x81\x46\x46\x4d\x0c\xd4\xd0\x34\x03\x26\x22\xa0\x8b\xd7\x27\x01\x34\x80\xf2\xfa\x06\xb3\xa2\x23\x81\x26\x83\xeb\xe4\x56\xb2\x05\x86\xf1\x55\x05\x95\x0c\xfa\x16\x3a\x06\x3f\x45\x2c\x2e\xa8\x93\x16\xb3\x07\x77\x1f\x76\xb5\xe5\x99\x84\x81\x2b\x20\x49\xb4\x98\x0a\x38\xa2\xc1\xf4\x80\xd4\x2a\xa4\x01\x76\x3e\x47\xf8\xbb\x92\x29\xc2\x84\x85\x44\xa7\x80\x68"
+
+func xzBytes(t testing.TB, b []byte) []byte {
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdBytes(t testing.TB, b []byte) []byte {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	return enc.EncodeAll(b, nil)
+}
+
+func TestDecompress(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		contents []byte
+		want     error
+	}{
+		{"gzip", "primary.xml.gz", gzipBytes(t, []byte(testXML)), nil},
+		{"xz", "primary.xml.xz", xzBytes(t, []byte(testXML)), nil},
+		{"zstd", "primary.xml.zst", zstdBytes(t, []byte(testXML)), nil},
+		{"bzip2", "primary.xml.bz2", []byte(testBzip2XML), nil},
+		{"xz without an extension", "primary", xzBytes(t, []byte(testXML)), nil},
+		{"plain", "primary.xml", []byte(testXML), nil},
+		{"plain with a gz extension", "primary.xml.gz", []byte(testXML), nil},
+		{"plain with a zck extension", "primary.xml.zck", []byte(testXML), nil},
+		{"zchunk", "primary.xml.zck", append([]byte("\x00ZCK1"), make([]byte, 64)...), ErrUnsupportedCompression},
+		{"truncated gzip", "primary.xml.gz", []byte{0x1F, 0x8B, 0x08}, ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, closure, err := decompress(bytes.NewReader(tt.contents), tt.fileName, func() {})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			defer closure()
+			contents, err := io.ReadAll(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(contents) != testXML {
+				t.Errorf("read %q, want %q", contents, testXML)
+			}
+		})
+	}
+}
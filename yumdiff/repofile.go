@@ -0,0 +1,194 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RepoConfig is one [section] of a yum .repo file
+type RepoConfig struct {
+	ID           string
+	Name         string
+	BaseURL      []string
+	Mirrorlist   string
+	Metalink     string
+	Enabled      bool
+	GPGCheck     bool
+	RepoGPGCheck bool
+	GPGKey       []string
+}
+
+// ReadRepoFile parses the INI sections of a .repo file, the yum variables such
+// as $basearch and $releasever are substituted from vars.  Variables which are
+// not in vars are left as is, like yum does, only a baseurl, mirrorlist or
+// metalink of an enabled repo which still holds one is an error.
+func ReadRepoFile(fileName string, vars map[string]string) ([]RepoConfig, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sections []map[string]string
+	var ids []string
+	var section map[string]string
+	var lastKey string
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";"):
+			continue
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			section = make(map[string]string)
+			sections = append(sections, section)
+			ids = append(ids, strings.TrimSpace(trimmed[1:len(trimmed)-1]))
+			lastKey = ""
+		case section == nil:
+			return nil, fmt.Errorf("%s:%d: option outside of a section", fileName, lineNo)
+		case (line[0] == ' ' || line[0] == '\t') && lastKey != "":
+			// A continuation of the previous value, like a list of baseurls
+			section[lastKey] += "\n" + trimmed
+		default:
+			i := strings.IndexAny(trimmed, "=:")
+			if i < 0 {
+				return nil, fmt.Errorf("%s:%d: expected key=value, got %q", fileName, lineNo, trimmed)
+			}
+			lastKey = strings.ToLower(strings.TrimSpace(trimmed[:i]))
+			section[lastKey] = strings.TrimSpace(trimmed[i+1:])
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var repos []RepoConfig
+	for i, sec := range sections {
+		for k, v := range sec {
+			sec[k] = substituteVars(v, vars)
+		}
+		rc := RepoConfig{
+			ID:           ids[i],
+			Name:         sec["name"],
+			BaseURL:      splitList(sec["baseurl"]),
+			Mirrorlist:   sec["mirrorlist"],
+			Metalink:     sec["metalink"],
+			Enabled:      parseBool(sec["enabled"], true),
+			GPGCheck:     parseBool(sec["gpgcheck"], false),
+			RepoGPGCheck: parseBool(sec["repo_gpgcheck"], false),
+			GPGKey:       splitList(sec["gpgkey"]),
+		}
+		if rc.Enabled {
+			for _, k := range []string{"baseurl", "mirrorlist", "metalink"} {
+				for _, u := range splitList(sec[k]) {
+					if name := undefinedVar(u); name != "" {
+						return nil, fmt.Errorf("%s [%s] %s: undefined variable $%s", fileName, ids[i], k, name)
+					}
+				}
+			}
+		}
+		repos = append(repos, rc)
+	}
+	return repos, nil
+}
+
+var yumVar = regexp.MustCompile(`\$(\w+)|\$\{(\w+)\}`)
+
+// substituteVars replaces the $var and ${var} references in a value, any not
+// found in vars are left as they are
+func substituteVars(value string, vars map[string]string) string {
+	return yumVar.ReplaceAllStringFunc(value, func(m string) string {
+		if v, ok := vars[strings.Trim(m, "${}")]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// undefinedVar returns the name of the first variable left in a location, the
+// query string is not checked as mirrorlists are passed variables (such as
+// $infra in CentOS-Base.repo) which the server is fine to see unset
+func undefinedVar(location string) string {
+	if i := strings.Index(location, "?"); i >= 0 {
+		location = location[:i]
+	}
+	if m := yumVar.FindStringSubmatch(location); m != nil {
+		return m[1] + m[2]
+	}
+	return ""
+}
+
+// splitList splits a value holding a whitespace or comma separated list
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+func parseBool(value string, def bool) bool {
+	switch strings.ToLower(value) {
+	case "1", "yes", "true", "on":
+		return true
+	case "0", "no", "false", "off":
+		return false
+	}
+	return def
+}
+
+// Source builds the mirrors to try for the repo, baseurls are preferred over
+// the metalink and mirrorlist as yum does
+func (rc RepoConfig) Source(ctx context.Context) (*Source, error) {
+	switch {
+	case len(rc.BaseURL) > 0:
+		src := &Source{}
+		for _, u := range rc.BaseURL {
+			// A local baseurl points at the repository base, and a local source
+			// is the repodata/ dir itself
+			if strings.HasPrefix(u, "file://") {
+				u = path.Join(strings.TrimPrefix(u, "file://"), "repodata")
+			}
+			src.Mirrors = append(src.Mirrors, u)
+		}
+		return src, nil
+	case rc.Metalink != "":
+		return readMetalink(ctx, rc.Metalink)
+	case rc.Mirrorlist != "":
+		return readMirrorlist(ctx, rc.Mirrorlist)
+	}
+	return nil, fmt.Errorf("Repo %s has no baseurl, metalink or mirrorlist", rc.ID)
+}
+
+// PathPrefix gives the path to use in the file list for the repo, either the
+// section id or the path of the first baseurl
+func (rc RepoConfig) PathPrefix(from string) string {
+	if from == "baseurl" && len(rc.BaseURL) > 0 {
+		if u, err := url.Parse(rc.BaseURL[0]); err == nil {
+			if p := strings.Trim(u.Path, "/"); p != "" {
+				return p
+			}
+		}
+	}
+	return rc.ID
+}
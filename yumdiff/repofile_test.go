@@ -0,0 +1,80 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The stock CentOS 7 base repos, the $infra variable is only set on CentOS
+const testCentOSBase = `[base]
+name=CentOS-$releasever - Base
+mirrorlist=http://mirrorlist.centos.org/?release=$releasever&arch=$basearch&repo=os&infra=$infra
+#baseurl=http://mirror.centos.org/centos/$releasever/os/$basearch/
+gpgcheck=1
+gpgkey=file:///etc/pki/rpm-gpg/RPM-GPG-KEY-CentOS-7
+
+[centosplus]
+name=CentOS-$releasever - Plus
+baseurl=http://mirror.centos.org/$contentdir/$releasever/centosplus/$basearch/
+gpgcheck=1
+enabled=0
+`
+
+func writeRepoFile(t *testing.T, contents string) string {
+	fileName := filepath.Join(t.TempDir(), "test.repo")
+	if err := os.WriteFile(fileName, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestReadRepoFile(t *testing.T) {
+	vars := map[string]string{"releasever": "7", "basearch": "x86_64"}
+	repos, err := ReadRepoFile(writeRepoFile(t, testCentOSBase), vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("got %d repos, want 2", len(repos))
+	}
+	want := RepoConfig{
+		ID:         "base",
+		Name:       "CentOS-7 - Base",
+		BaseURL:    []string{},
+		Mirrorlist: "http://mirrorlist.centos.org/?release=7&arch=x86_64&repo=os&infra=$infra",
+		Enabled:    true,
+		GPGCheck:   true,
+		GPGKey:     []string{"file:///etc/pki/rpm-gpg/RPM-GPG-KEY-CentOS-7"},
+	}
+	if !reflect.DeepEqual(repos[0], want) {
+		t.Errorf("got %+v, want %+v", repos[0], want)
+	}
+	if repos[1].Enabled || repos[1].BaseURL[0] != "http://mirror.centos.org/$contentdir/7/centosplus/x86_64/" {
+		t.Errorf("got %+v", repos[1])
+	}
+}
+
+func TestReadRepoFileUndefinedVar(t *testing.T) {
+	repoFile := writeRepoFile(t, strings.Replace(testCentOSBase, "enabled=0", "enabled=1", 1))
+	_, err := ReadRepoFile(repoFile, map[string]string{"releasever": "7", "basearch": "x86_64"})
+	if err == nil || !strings.Contains(err.Error(), "[centosplus] baseurl: undefined variable $contentdir") {
+		t.Errorf("got %v, want an undefined $contentdir", err)
+	}
+}
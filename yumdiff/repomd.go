@@ -0,0 +1,216 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+type Repomd struct {
+	XMLName         xml.Name     `xml:"repomd"`
+	Text            string       `xml:",chardata"`
+	Xmlns           string       `xml:"xmlns,attr"`
+	Rpm             string       `xml:"rpm,attr"`
+	Revision        string       `xml:"revision"`
+	Data            []RepomdData `xml:"data"`
+	fileContents    []byte
+	ascFileContents string
+	path            string
+	mirror          string
+}
+
+type RepomdData struct {
+	Text     string   `xml:",chardata"`
+	Type     string   `xml:"type,attr"`
+	Checksum Checksum `xml:"checksum"`
+	Location struct {
+		Text string `xml:",chardata"`
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+	Timestamp       float64  `xml:"timestamp"`
+	Size            int      `xml:"size"`
+	OpenChecksum    Checksum `xml:"open-checksum"`
+	OpenSize        string   `xml:"open-size"`
+	DatabaseVersion string   `xml:"database_version"`
+}
+
+type Checksum struct {
+	Text string `xml:",chardata"`
+	Type string `xml:"type,attr"`
+}
+
+func readRepomdFile(ctx context.Context, repomdFile string) (*Repomd, error) {
+	Logger.Println("Reading in file", repomdFile)
+	contents, err := readAll(ctx, repomdFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrRepomdMissing, repomdFile)
+	} else if err != nil {
+		return nil, fmt.Errorf("Error reading in Repomd %s: %w", repomdFile, err)
+	}
+
+	var dat Repomd
+	err = xml.Unmarshal(contents, &dat)
+	if err != nil {
+		return nil, fmt.Errorf("%w, error in decoding Repomd %s: %w", ErrMalformed, repomdFile, err)
+	}
+	dat.fileContents = contents
+	dat.path = repomdFile
+
+	return &dat, nil
+}
+
+// dataFile gives the location of the file of a data entry, the base of a local
+// href is taken from the repodata/ dir as the dir may have been copied on its own
+func (r *Repomd) dataFile(d RepomdData) string {
+	if r.mirror == "" {
+		return path.Join(path.Dir(r.path), path.Base(d.Location.Href))
+	}
+	return joinURL(r.mirror, d.Location.Href)
+}
+
+// findData returns the data entry of the type, or nil when there is none
+func (r *Repomd) findData(dataType string) *RepomdData {
+	if r == nil {
+		return nil
+	}
+	for i := range r.Data {
+		if r.Data[i].Type == dataType {
+			return &r.Data[i]
+		}
+	}
+	return nil
+}
+
+// readWithChecksum reads in the whole contents of a local file or URL and
+// verifies it against the checksum
+func readWithChecksum(ctx context.Context, fileName string, sum Checksum) ([]byte, error) {
+	contents, err := readAll(ctx, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := newHash(sum.Type)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	h.Write(contents)
+
+	if got := fmt.Sprintf("%x", h.Sum(nil)); got != sum.Text {
+		return nil, fmt.Errorf("%w for %s, expected {%s}%s got %s", ErrChecksumMismatch, fileName, sum.Type, sum.Text, got)
+	}
+	return contents, nil
+}
+
+// newHash returns the hash function for a checksum type used in the repodata
+func newHash(checksumType string) (hash.Hash, error) {
+	switch checksumType {
+	case "sha", "sha1":
+		return sha1.New(), nil
+	case "sha224":
+		return sha256.New224(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha384":
+		return sha512.New384(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("Unsupported checksum type %q", checksumType)
+}
+
+// verifyFile hashes the file on disk and compares it to the checksum
+func verifyFile(fileName string, sum Checksum) error {
+	h, err := newHash(sum.Type)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fileName, err)
+	}
+	rawFile, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer rawFile.Close()
+	if _, err = io.Copy(h, rawFile); err != nil {
+		return err
+	}
+	if got := fmt.Sprintf("%x", h.Sum(nil)); got != sum.Text {
+		return fmt.Errorf("%w for %s, expected {%s}%s got %s", ErrChecksumMismatch, fileName, sum.Type, sum.Text, got)
+	}
+	return nil
+}
+
+// verifyReader hashes everything read through it and compares the result with
+// the expected checksum once the underlying reader hits EOF
+type verifyReader struct {
+	r        io.Reader
+	h        hash.Hash
+	sum      Checksum
+	fileName string
+}
+
+func (v *verifyReader) Read(p []byte) (n int, err error) {
+	n, err = v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF {
+		if got := fmt.Sprintf("%x", v.h.Sum(nil)); got != v.sum.Text {
+			return n, fmt.Errorf("%w (open-checksum) for %s, expected {%s}%s got %s", ErrChecksumMismatch, v.fileName, v.sum.Type, v.sum.Text, got)
+		}
+	}
+	return
+}
+
+// openData opens a file referenced from the repomd.xml, the file is verified
+// against the checksum and the decompressed stream against the open-checksum,
+// which is checked when the returned reader is read to the end.
+func openData(ctx context.Context, fileName string, d *RepomdData) (file io.Reader, closure func(), err error) {
+	if IsURL(fileName) {
+		// Remote files are held in memory so they are only downloaded once
+		Logger.Println("Reading in file", fileName)
+		contents, err := readWithChecksum(ctx, fileName, d.Checksum)
+		if err != nil {
+			return nil, nil, err
+		}
+		if file, closure, err = decompress(bytes.NewReader(contents), fileName, func() {}); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if err = verifyFile(fileName, d.Checksum); err != nil {
+			return
+		}
+		if file, closure, err = open(ctx, fileName); err != nil {
+			return
+		}
+	}
+	if d.OpenChecksum.Text != "" {
+		h, err := newHash(d.OpenChecksum.Type)
+		if err != nil {
+			closure()
+			return nil, nil, fmt.Errorf("%s: %v", fileName, err)
+		}
+		file = &verifyReader{r: file, h: h, sum: d.OpenChecksum, fileName: fileName}
+	}
+	return
+}
@@ -0,0 +1,130 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testXML = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" packages="0">
+</metadata>
+`
+
+func sha256Sum(b []byte) string { return fmt.Sprintf("%x", sha256.Sum256(b)) }
+
+func gzipBytes(t testing.TB, b []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpenDataChecksums(t *testing.T) {
+	compressed := gzipBytes(t, []byte(testXML))
+	fileName := filepath.Join(t.TempDir(), "primary.xml.gz")
+	if err := os.WriteFile(fileName, compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	good := Checksum{Type: "sha256", Text: sha256Sum(compressed)}
+	goodOpen := Checksum{Type: "sha256", Text: sha256Sum([]byte(testXML))}
+	bad := Checksum{Type: "sha256", Text: strings.Repeat("0", 64)}
+
+	tests := []struct {
+		name                   string
+		checksum, openChecksum Checksum
+		wantOpen, wantRead     error
+	}{
+		{name: "good", checksum: good, openChecksum: goodOpen},
+		{name: "no open-checksum", checksum: good},
+		{name: "bad checksum", checksum: bad, openChecksum: goodOpen, wantOpen: ErrChecksumMismatch},
+		{name: "bad open-checksum", checksum: good, openChecksum: bad, wantRead: ErrChecksumMismatch},
+		{name: "sha1 checksum", checksum: Checksum{Type: "sha", Text: fmt.Sprintf("%x", sha1.Sum(compressed))}},
+		{name: "unknown checksum type", checksum: Checksum{Type: "md5", Text: good.Text}, wantOpen: errUnsupported},
+		{name: "unknown open-checksum type", checksum: good, openChecksum: Checksum{Type: "md5", Text: goodOpen.Text}, wantOpen: errUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &RepomdData{Checksum: tt.checksum, OpenChecksum: tt.openChecksum}
+			file, closure, err := openData(context.Background(), fileName, d)
+			if !matchError(err, tt.wantOpen) {
+				t.Fatalf("openData: got %v, want %v", err, tt.wantOpen)
+			}
+			if err != nil {
+				return
+			}
+			defer closure()
+			contents, err := io.ReadAll(file)
+			if !matchError(err, tt.wantRead) {
+				t.Fatalf("read: got %v, want %v", err, tt.wantRead)
+			}
+			if err == nil && string(contents) != testXML {
+				t.Errorf("read %q, want %q", contents, testXML)
+			}
+		})
+	}
+}
+
+func TestVerifyReader(t *testing.T) {
+	tests := []struct {
+		name string
+		sum  Checksum
+		want error
+	}{
+		{"match", Checksum{Type: "sha256", Text: sha256Sum([]byte(testXML))}, nil},
+		{"mismatch", Checksum{Type: "sha256", Text: sha256Sum([]byte("other"))}, ErrChecksumMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := newHash(tt.sum.Type)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := &verifyReader{r: strings.NewReader(testXML), h: h, sum: tt.sum, fileName: "test.xml"}
+			if _, err := io.ReadAll(v); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// errUnsupported stands in for the untyped error of an unsupported checksum
+// type in the tables above
+var errUnsupported = errors.New("Unsupported checksum type")
+
+// matchError reports if err is the wanted error, for errUnsupported only the
+// message is compared
+func matchError(err, want error) bool {
+	if want == errUnsupported {
+		return err != nil && strings.Contains(err.Error(), want.Error())
+	}
+	return errors.Is(err, want)
+}
@@ -0,0 +1,162 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EVR is the epoch, version and release of a package as found in the version
+// element of the primary.xml file
+type EVR struct {
+	Epoch string `xml:"epoch,attr"`
+	Ver   string `xml:"ver,attr"`
+	Rel   string `xml:"rel,attr"`
+}
+
+func (v EVR) String() string {
+	if v.Epoch == "" || v.Epoch == "0" {
+		return v.Ver + "-" + v.Rel
+	}
+	return v.Epoch + ":" + v.Ver + "-" + v.Rel
+}
+
+// CompareEVR orders two EVRs the same way rpm does, returning -1, 0 or 1
+func CompareEVR(a, b EVR) int {
+	ea, _ := strconv.ParseUint(a.Epoch, 10, 64)
+	eb, _ := strconv.ParseUint(b.Epoch, 10, 64)
+	switch {
+	case ea < eb:
+		return -1
+	case ea > eb:
+		return 1
+	}
+	if c := Rpmvercmp(a.Ver, b.Ver); c != 0 {
+		return c
+	}
+	return Rpmvercmp(a.Rel, b.Rel)
+}
+
+// Rpmvercmp is a port of the rpmvercmp() function from rpm's lib/rpmvercmp.c,
+// including the handling of the tilde (sorts before anything) and caret (sorts
+// after the base version, but before anything else) separators.
+func Rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for len(a) > 0 || len(b) > 0 {
+		a = strings.TrimLeftFunc(a, isVerSeparator)
+		b = strings.TrimLeftFunc(b, isVerSeparator)
+
+		// Handle the tilde separator, it sorts before everything else
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// Handle the caret separator, it is like the tilde except that when one
+		// of the strings ends, that string is considered the older one
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if len(a) == 0 {
+				return -1
+			}
+			if len(b) == 0 {
+				return 1
+			}
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if len(a) == 0 || len(b) == 0 {
+			break
+		}
+
+		// Grab the first completely alpha or completely numeric segment, the
+		// segment type is taken from the first string
+		isNum := isDigit(rune(a[0]))
+		segFunc := isAlpha
+		if isNum {
+			segFunc = isDigit
+		}
+		segA, segB := a, b
+		if i := strings.IndexFunc(a, not(segFunc)); i >= 0 {
+			segA, a = a[:i], a[i:]
+		} else {
+			a = ""
+		}
+		if i := strings.IndexFunc(b, not(segFunc)); i >= 0 {
+			segB, b = b[:i], b[i:]
+		} else {
+			b = ""
+		}
+
+		// Segments of differing types, numeric segments are always newer
+		if len(segB) == 0 {
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+
+		if isNum {
+			// Throw away any leading zeros, the longer number wins
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+			if len(segA) > len(segB) {
+				return 1
+			}
+			if len(segB) > len(segA) {
+				return -1
+			}
+		}
+
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	if len(a) == 0 {
+		return -1
+	}
+	return 1
+}
+
+func isDigit(r rune) bool { return r >= '0' && r <= '9' }
+func isAlpha(r rune) bool { return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') }
+func not(f func(rune) bool) func(rune) bool {
+	return func(r rune) bool { return !f(r) }
+}
+
+// isVerSeparator matches the characters which are skipped between segments
+func isVerSeparator(r rune) bool {
+	return !isDigit(r) && !isAlpha(r) && r != '~' && r != '^'
+}
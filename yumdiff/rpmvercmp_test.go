@@ -0,0 +1,165 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import "testing"
+
+// rpmvercmpTests are the test vectors from rpm's tests/rpmvercmp.at
+var rpmvercmpTests = []struct {
+	a, b string
+	want int
+}{
+	{"1.0", "1.0", 0},
+	{"1.0", "2.0", -1},
+	{"2.0", "1.0", 1},
+
+	{"2.0.1", "2.0.1", 0},
+	{"2.0", "2.0.1", -1},
+	{"2.0.1", "2.0", 1},
+
+	{"2.0.1a", "2.0.1a", 0},
+	{"2.0.1a", "2.0.1", 1},
+	{"2.0.1", "2.0.1a", -1},
+
+	{"5.5p1", "5.5p1", 0},
+	{"5.5p1", "5.5p2", -1},
+	{"5.5p2", "5.5p1", 1},
+
+	{"5.5p10", "5.5p10", 0},
+	{"5.5p1", "5.5p10", -1},
+	{"5.5p10", "5.5p1", 1},
+
+	{"10xyz", "10.1xyz", -1},
+	{"10.1xyz", "10xyz", 1},
+
+	{"xyz10", "xyz10", 0},
+	{"xyz10", "xyz10.1", -1},
+	{"xyz10.1", "xyz10", 1},
+
+	{"xyz.4", "xyz.4", 0},
+	{"xyz.4", "8", -1},
+	{"8", "xyz.4", 1},
+	{"xyz.4", "2", -1},
+	{"2", "xyz.4", 1},
+
+	{"5.5p2", "5.6p1", -1},
+	{"5.6p1", "5.5p2", 1},
+
+	{"5.6p1", "6.5p1", -1},
+	{"6.5p1", "5.6p1", 1},
+
+	{"6.0.rc1", "6.0", 1},
+	{"6.0", "6.0.rc1", -1},
+
+	{"10b2", "10a1", 1},
+	{"10a2", "10b2", -1},
+
+	{"1.0aa", "1.0aa", 0},
+	{"1.0a", "1.0aa", -1},
+	{"1.0aa", "1.0a", 1},
+
+	{"10.0001", "10.0001", 0},
+	{"10.0001", "10.1", 0},
+	{"10.1", "10.0001", 0},
+	{"10.0001", "10.0039", -1},
+	{"10.0039", "10.0001", 1},
+
+	{"4.999.9", "5.0", -1},
+	{"5.0", "4.999.9", 1},
+
+	{"20101121", "20101121", 0},
+	{"20101121", "20101122", -1},
+	{"20101122", "20101121", 1},
+
+	{"2_0", "2_0", 0},
+	{"2.0", "2_0", 0},
+	{"2_0", "2.0", 0},
+
+	// Non-alphanumeric separators
+	{"a", "a", 0},
+	{"a+", "a+", 0},
+	{"a+", "a_", 0},
+	{"a_", "a+", 0},
+	{"+a", "+a", 0},
+	{"+a", "_a", 0},
+	{"_a", "+a", 0},
+	{"+_", "+_", 0},
+	{"_+", "+_", 0},
+	{"_+", "_+", 0},
+	{"+", "_", 0},
+	{"_", "+", 0},
+
+	// Tilde
+	{"1.0~rc1", "1.0~rc1", 0},
+	{"1.0~rc1", "1.0", -1},
+	{"1.0", "1.0~rc1", 1},
+	{"1.0~rc1", "1.0~rc2", -1},
+	{"1.0~rc2", "1.0~rc1", 1},
+	{"1.0~rc1~git123", "1.0~rc1~git123", 0},
+	{"1.0~rc1~git123", "1.0~rc1", -1},
+	{"1.0~rc1", "1.0~rc1~git123", 1},
+
+	// Caret
+	{"1.0^", "1.0^", 0},
+	{"1.0^", "1.0", 1},
+	{"1.0", "1.0^", -1},
+	{"1.0^git1", "1.0^git1", 0},
+	{"1.0^git1", "1.0", 1},
+	{"1.0", "1.0^git1", -1},
+	{"1.0^git1", "1.0^git2", -1},
+	{"1.0^git2", "1.0^git1", 1},
+	{"1.0^git1", "1.01", -1},
+	{"1.01", "1.0^git1", 1},
+	{"1.0^20160101", "1.0^20160101", 0},
+	{"1.0^20160101", "1.0.1", -1},
+	{"1.0.1", "1.0^20160101", 1},
+	{"1.0^20160101^git1", "1.0^20160101^git1", 0},
+	{"1.0^20160102", "1.0^20160101^git1", 1},
+	{"1.0^20160101^git1", "1.0^20160102", -1},
+
+	// Tilde and caret together
+	{"1.0~rc1^git1", "1.0~rc1^git1", 0},
+	{"1.0~rc1^git1", "1.0~rc1", 1},
+	{"1.0~rc1", "1.0~rc1^git1", -1},
+	{"1.0^git1~pre", "1.0^git1~pre", 0},
+	{"1.0^git1", "1.0^git1~pre", 1},
+	{"1.0^git1~pre", "1.0^git1", -1},
+}
+
+func TestRpmvercmp(t *testing.T) {
+	for _, tt := range rpmvercmpTests {
+		if got := Rpmvercmp(tt.a, tt.b); got != tt.want {
+			t.Errorf("Rpmvercmp(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCompareEVR(t *testing.T) {
+	tests := []struct {
+		a, b EVR
+		want int
+	}{
+		{EVR{"0", "1.0", "1"}, EVR{"", "1.0", "1"}, 0},
+		{EVR{"1", "1.0", "1"}, EVR{"0", "2.0", "1"}, 1},
+		{EVR{"0", "2.0", "1"}, EVR{"1", "1.0", "1"}, -1},
+		{EVR{"0", "1.0", "2.el7"}, EVR{"0", "1.0", "10.el7"}, -1},
+		{EVR{"0", "1.0", "1.el7_9"}, EVR{"0", "1.0", "1.el7"}, 1},
+	}
+	for _, tt := range tests {
+		if got := CompareEVR(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareEVR(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yumdiff
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Update is an advisory (errata) of the updateinfo.xml file, such as an RHSA
+type Update struct {
+	ID       string `xml:"id"`
+	Type     string `xml:"type,attr"`
+	Status   string `xml:"status,attr"`
+	Title    string `xml:"title"`
+	Severity string `xml:"severity"`
+	Issued   struct {
+		Date string `xml:"date,attr"`
+	} `xml:"issued"`
+	Updated struct {
+		Date string `xml:"date,attr"`
+	} `xml:"updated"`
+	References []struct {
+		ID   string `xml:"id,attr"`
+		Type string `xml:"type,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"references>reference"`
+	Packages []UpdatePackage `xml:"pkglist>collection>package"`
+}
+
+// UpdatePackage is a package fixed by an advisory
+type UpdatePackage struct {
+	Name     string `xml:"name,attr"`
+	Epoch    string `xml:"epoch,attr"`
+	Version  string `xml:"version,attr"`
+	Release  string `xml:"release,attr"`
+	Arch     string `xml:"arch,attr"`
+	Filename string `xml:"filename"`
+}
+
+// NEVRA gives the name-[epoch:]version-release.arch of the package
+func (p UpdatePackage) NEVRA() string {
+	return p.Name + "-" + EVR{Epoch: p.Epoch, Ver: p.Version, Rel: p.Release}.String() + "." + p.Arch
+}
+
+// readUpdateinfo reads in the updateinfo file, the <update> elements are
+// decoded one at a time
+func readUpdateinfo(ctx context.Context, fileName string, d *RepomdData) ([]Update, error) {
+	var updates []Update
+	err := streamFile(ctx, fileName, d, func(decoder *xml.Decoder, se xml.StartElement) error {
+		if se.Name.Local != "update" {
+			return nil
+		}
+		var u Update
+		if err := decoder.DecodeElement(&u, &se); err != nil {
+			return decodeError(fileName, err)
+		}
+		updates = append(updates, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// indexUpdates maps the NEVRA of every package onto the advisories covering it
+func indexUpdates(updates []Update) map[string][]int {
+	idx := make(map[string][]int)
+	for i, u := range updates {
+		seen := make(map[string]bool)
+		for _, p := range u.Packages {
+			if n := p.NEVRA(); !seen[n] {
+				seen[n] = true
+				idx[n] = append(idx[n], i)
+			}
+		}
+	}
+	return idx
+}
+
+// Advisories returns the advisories covering the package, which are only
+// known when the repository was loaded with FieldNEVRA and FieldAdvisories
+func (r *Repo) Advisories(m Matchable) (found []Update) {
+	p, ok := m.(Package)
+	if !ok || r == nil || p.Name == "" {
+		return
+	}
+	for _, i := range r.updateIndex[p.NEVRA()] {
+		found = append(found, r.Updates[i])
+	}
+	return
+}
+
+// severities orders the advisory severities, from the least to most severe
+var severities = []string{"none", "low", "moderate", "important", "critical"}
+
+// SeverityRank gives the order of the severity, a missing or unknown severity
+// ranks lowest
+func SeverityRank(severity string) int {
+	for i, s := range severities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return 0
+}
+
+// AdvisoryFilter selects the advisories of the types and at least the
+// severity given, an empty field matches any
+type AdvisoryFilter struct {
+	Types       []string
+	MinSeverity string
+}
+
+// NewAdvisoryFilter checks the minimum severity is a known one
+func NewAdvisoryFilter(types []string, minSeverity string) (*AdvisoryFilter, error) {
+	if minSeverity != "" && SeverityRank(minSeverity) == 0 && !strings.EqualFold(minSeverity, "none") {
+		return nil, fmt.Errorf("Unknown severity %q, expected one of Low, Moderate, Important or Critical", minSeverity)
+	}
+	return &AdvisoryFilter{Types: types, MinSeverity: minSeverity}, nil
+}
+
+// Match reports if the advisory passes the filter
+func (f *AdvisoryFilter) Match(u Update) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if strings.EqualFold(t, u.Type) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return SeverityRank(u.Severity) >= SeverityRank(f.MinSeverity)
+}
+
+// MatchAny reports if any of the advisories passes the filter
+func (f *AdvisoryFilter) MatchAny(updates []Update) bool {
+	for _, u := range updates {
+		if f.Match(u) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+// Written by Paul Schou (paulschou.com) March 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package yumdiff loads yum repository metadata (the repomd.xml and the
+// primary and prestodelta files it lists, with the filelists and other files
+// when asked for) from local dirs, URLs, mirrorlists or metalinks and compares
+// two repositories to find the files which have been added, removed or are
+// common to both.
+//
+//	src, err := yumdiff.ResolveSource(ctx, "https://mirror/7/os/x86_64/")
+//	newRepo, err := yumdiff.LoadRepo(ctx, src)
+//	...
+//	result, err := yumdiff.Diff(oldRepo, newRepo, yumdiff.DiffOptions{})
+//	for _, m := range result.Added {
+//		e := m.Entry()
+//		fmt.Printf("{%s}%s %d %s\n", e.ChecksumType, e.Checksum, e.Size, e.Href)
+//	}
+package yumdiff
+
+import (
+	"log"
+	"os"
+)
+
+// Logger receives the progress messages, such as the files being read in
+var Logger = log.New(os.Stderr, "", log.LstdFlags)